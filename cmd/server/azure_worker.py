@@ -1,72 +1,259 @@
+import os
 import sys
 import json
+import struct
+import threading
+import time
 import argparse
 import logging
 import azure.cognitiveservices.speech as speechsdk
 
-# Logging auf stderr, damit JSON auf stdout sauber bleibt
-logging.basicConfig(stream=sys.stderr, level=logging.INFO)
+# Logging auf stderr, damit die Frames auf stdout sauber bleiben.
+# Go übernimmt den Level-Präfix in das strukturierte Room-Log.
+logging.basicConfig(stream=sys.stderr, level=logging.INFO, format="%(levelname)s %(message)s")
 
-def run(key, region):
-    logging.info(f"Starting Azure Python Worker (Region: {region})")
+# Azure liefert Offsets und Dauer in Ticks (100 ns)
+TICKS_PER_SECOND = 10_000_000
+
+
+def ticks_to_seconds(ticks):
+    return round(ticks / TICKS_PER_SECOND, 3)
+
+
+def extract_words(result):
+    """Liest Wort-Timings aus der detaillierten JSON-Antwort (nur bei finalen Ergebnissen vorhanden)."""
+    try:
+        detail = json.loads(result.json)
+    except (TypeError, ValueError):
+        return []
+
+    nbest = detail.get("NBest") or []
+    if not nbest:
+        return []
+
+    words = []
+    for w in nbest[0].get("Words") or []:
+        start = w.get("Offset", 0)
+        words.append({
+            "text": w.get("Word", ""),
+            "start": ticks_to_seconds(start),
+            "end": ticks_to_seconds(start + w.get("Duration", 0)),
+            "confidence": w.get("Confidence", 0.0),
+        })
+    return words
+
+
+def detected_language(result):
+    """Liefert die erkannte Sprache bei aktivierter Spracherkennung, sonst None."""
+    return result.properties.get(
+        speechsdk.PropertyId.SpeechServiceConnection_AutoDetectSourceLanguageResult
+    )
+
+
+def build_output(result, is_partial):
+    # Speaker ID ist bei finalen Ergebnissen meist verlässlich (z.B. "Guest-1")
+    speaker_id = result.speaker_id if result.speaker_id else "Unknown"
+    words = [] if is_partial else extract_words(result)
+    # Azure liefert den Speaker pro Phrase, alle Wörter erben ihn
+    for word in words:
+        word["speaker"] = speaker_id
+    return {
+        "language": detected_language(result) or "",
+        "text": result.text,
+        "is_partial": is_partial,
+        "speaker": speaker_id,
+        "start": ticks_to_seconds(result.offset),
+        "end": ticks_to_seconds(result.offset + result.duration),
+        "words": words,
+    }
+
+
+# Azure CancellationErrorCode -> Fehlerklasse für Go (auth, quota, network, service)
+ERROR_KINDS = {
+    speechsdk.CancellationErrorCode.AuthenticationFailure: "auth",
+    speechsdk.CancellationErrorCode.Forbidden: "auth",
+    speechsdk.CancellationErrorCode.TooManyRequests: "quota",
+    speechsdk.CancellationErrorCode.ConnectionFailure: "network",
+    speechsdk.CancellationErrorCode.ServiceTimeout: "network",
+}
+
+
+# Frame-Protokoll (siehe internal/transcription/stdio/frame.go):
+# 1 Byte Typ, 4 Byte Länge (big-endian), danach die Nutzdaten
+FRAME_AUDIO = b"A"
+FRAME_CONFIG = b"C"
+FRAME_FLUSH = b"F"
+FRAME_STOP = b"S"
+FRAME_RESULT = b"R"
+FRAME_READY = b"Y"
+FRAME_HEARTBEAT = b"H"
+FRAME_ERROR = b"E"
+FRAME_ACTIVITY = b"V"
+
+HEARTBEAT_INTERVAL = 5
+
+# 1 Sekunde Stille (16 kHz, 16 Bit mono), damit Azure die laufende Phrase abschliesst
+FLUSH_SILENCE = bytes(32000)
+
+_write_lock = threading.Lock()
+
+
+def write_frame(frame_type, payload=b""):
+    with _write_lock:
+        sys.stdout.buffer.write(frame_type + struct.pack(">I", len(payload)) + payload)
+        sys.stdout.buffer.flush()
+
+
+def write_json_frame(frame_type, obj):
+    write_frame(frame_type, json.dumps(obj).encode("utf-8"))
+
+
+def read_exact(stream, size):
+    data = b""
+    while len(data) < size:
+        chunk = stream.read(size - len(data))
+        if not chunk:
+            return None
+        data += chunk
+    return data
+
+
+def read_frame(stream):
+    """Liefert (Typ, Nutzdaten) oder None bei EOF."""
+    header = read_exact(stream, 5)
+    if header is None:
+        return None
+    (size,) = struct.unpack(">I", header[1:])
+    payload = read_exact(stream, size) if size else b""
+    if payload is None:
+        return None
+    return header[:1], payload
+
+
+def send_heartbeats():
+    while True:
+        time.sleep(HEARTBEAT_INTERVAL)
+        write_frame(FRAME_HEARTBEAT)
+
+
+def report_error(kind, message):
+    write_json_frame(FRAME_ERROR, {"kind": kind, "message": message})
+
+
+def handle_canceled(evt):
+    details = evt.cancellation_details
+    if details.reason != speechsdk.CancellationReason.Error:
+        logging.info(f"Canceled: {details.reason}")
+        return
+
+    kind = ERROR_KINDS.get(details.code, "service")
+    logging.error(f"Canceled ({details.code}): {details.error_details}")
+    report_error(kind, f"{details.code}: {details.error_details}")
+
+    # Der Transcriber ist tot: Prozess beenden, Go entscheidet über einen Neustart
+    os._exit(1)
+
+
+def apply_phrases(phrase_list, phrases):
+    phrase_list.clear()
+    for phrase in phrases:
+        phrase_list.addPhrase(phrase)
+    logging.info(f"Phrase list updated ({len(phrases)} phrases)")
+
+
+def run(key, region, config):
+    language = config.get("language") or os.environ.get("TOLKA_LANGUAGE", "de-CH")
+    candidates = config.get("languages") or [language]
+    auto_detect = bool(config.get("auto_detect"))
+
+    logging.info(f"Starting Azure Python Worker (Region: {region}, Language: {language}, Auto-Detect: {auto_detect})")
 
     speech_config = speechsdk.SpeechConfig(subscription=key, region=region)
-    speech_config.speech_recognition_language = "de-CH"
+
+    auto_detect_config = None
+    if auto_detect:
+        # Kontinuierliche Spracherkennung: Azure darf mitten im Gespräch die Sprache wechseln
+        speech_config.set_property(
+            property_id=speechsdk.PropertyId.SpeechServiceConnection_LanguageIdMode,
+            value='Continuous'
+        )
+        auto_detect_config = speechsdk.languageconfig.AutoDetectSourceLanguageConfig(languages=candidates)
+    else:
+        speech_config.speech_recognition_language = language
 
     speech_config.set_property(
         property_id=speechsdk.PropertyId.SpeechServiceResponse_DiarizeIntermediateResults,
         value='true'
     )
 
+    # Wort-Timings und Konfidenzen in der detaillierten Antwort anfordern
+    speech_config.output_format = speechsdk.OutputFormat.Detailed
+    speech_config.request_word_level_timestamps()
+
     # PushStream statt Datei: Wir empfangen Audio via Pipe (stdin)
     stream = speechsdk.audio.PushAudioInputStream()
     audio_config = speechsdk.audio.AudioConfig(stream=stream)
 
     # ConversationTranscriber initialisieren
-    transcriber = speechsdk.transcription.ConversationTranscriber(speech_config, audio_config)
+    transcriber = speechsdk.transcription.ConversationTranscriber(
+        speech_config=speech_config,
+        audio_config=audio_config,
+        auto_detect_source_language_config=auto_detect_config,
+    )
 
     # Callback für finale Ergebnisse (Satz beendet)
     def handle_final_result(evt):
         if evt.result.text:
-            # Speaker ID ist hier meist verlässlich (z.B. "Guest-1")
-            speaker_id = evt.result.speaker_id if evt.result.speaker_id else "Unknown"
-            output = {
-                "text": evt.result.text,
-                "is_partial": False,
-                "speaker": speaker_id
-            }
-            print(json.dumps(output), flush=True)
+            write_json_frame(FRAME_RESULT, build_output(evt.result, False))
 
     # Callback für Zwischenergebnisse (Wort für Wort live)
     def handle_partial_result(evt):
         if evt.result.text:
-            speaker_id = evt.result.speaker_id if evt.result.speaker_id else "Unknown"
-            output = {
-                "text": evt.result.text,
-                "is_partial": True,
-                "speaker": speaker_id
-            }
-            print(json.dumps(output), flush=True)
+            write_json_frame(FRAME_RESULT, build_output(evt.result, True))
+
+    # Session-Vokabular (Namen, Produkte, Orte) als PhraseListGrammar
+    phrase_list = speechsdk.PhraseListGrammar.from_recognizer(transcriber)
+    apply_phrases(phrase_list, config.get("vocabulary") or [])
 
     # Event Handler verknüpfen
     transcriber.transcribed.connect(handle_final_result)
     transcriber.transcribing.connect(handle_partial_result)
 
+    # Sprachaktivität: Zuschauer sehen, dass jemand spricht, bevor Wörter ankommen
+    transcriber.speech_start_detected.connect(
+        lambda evt: write_json_frame(FRAME_ACTIVITY, {"type": "speech_start", "time": ticks_to_seconds(evt.offset)})
+    )
+    transcriber.speech_end_detected.connect(
+        lambda evt: write_json_frame(FRAME_ACTIVITY, {"type": "speech_end", "time": ticks_to_seconds(evt.offset)})
+    )
+
     transcriber.session_started.connect(lambda evt: logging.info('Session started'))
     transcriber.session_stopped.connect(lambda evt: logging.info('Session stopped'))
-    transcriber.canceled.connect(lambda evt: logging.error(f'Canceled: {evt}'))
+    transcriber.canceled.connect(handle_canceled)
 
-    # Starten (Async, damit wir im Loop Audio schreiben können)
-    transcriber.start_transcribing_async()
+    # Starten und warten, bis die Verbindung steht, erst dann ist der Worker bereit
+    transcriber.start_transcribing_async().get()
+    write_frame(FRAME_READY)
+    threading.Thread(target=send_heartbeats, daemon=True).start()
 
-    # Streaming Loop: Liest Rohdaten von Go (via Stdin) und schiebt sie zu Azure
+    # Streaming Loop: Liest Frames von Go (via Stdin) und schiebt das Audio zu Azure
     try:
         while True:
-            # Lese 4096 Byte Chunks
-            chunk = sys.stdin.buffer.read(4096)
-            if not chunk:
+            frame = read_frame(sys.stdin.buffer)
+            if frame is None:
                 break # EOF (Go hat den Stream geschlossen)
-            stream.write(chunk)
+
+            frame_type, payload = frame
+            if frame_type == FRAME_AUDIO:
+                stream.write(payload)
+            elif frame_type == FRAME_CONFIG:
+                apply_phrases(phrase_list, json.loads(payload).get("vocabulary") or [])
+            elif frame_type == FRAME_FLUSH:
+                stream.write(FLUSH_SILENCE)
+            elif frame_type == FRAME_STOP:
+                break
+            else:
+                logging.warning(f"Unknown frame type {frame_type!r}")
 
     except KeyboardInterrupt:
         pass
@@ -75,13 +262,29 @@ def run(key, region):
     finally:
         # Sauber runterfahren
         stream.close()
-        transcriber.stop_transcribing_async()
+        transcriber.stop_transcribing_async().get()
         logging.info("Azure Python Worker stopped")
 
+
+def read_config():
+    """Der erste Frame von Go ist immer die Session-Konfiguration."""
+    frame = read_frame(sys.stdin.buffer)
+    if frame is None:
+        return None
+    frame_type, payload = frame
+    if frame_type != FRAME_CONFIG:
+        logging.error(f"Expected config frame, got {frame_type!r}")
+        return None
+    return json.loads(payload)
+
+
 if __name__ == "__main__":
     parser = argparse.ArgumentParser()
     parser.add_argument("--key", required=True)
     parser.add_argument("--region", required=True)
     args = parser.parse_args()
 
-    run(args.key, args.region)
\ No newline at end of file
+    config = read_config()
+    if config is None:
+        sys.exit(1)
+    run(args.key, args.region, config)
@@ -1,19 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/joshuabeny1999/tolka/internal/audiofile"
 	"github.com/joshuabeny1999/tolka/internal/config"
+	"github.com/joshuabeny1999/tolka/internal/export"
 	"github.com/joshuabeny1999/tolka/internal/middleware"
 	"github.com/joshuabeny1999/tolka/internal/spa"
+	"github.com/joshuabeny1999/tolka/internal/store"
 	"github.com/joshuabeny1999/tolka/internal/transcription"
 	"github.com/joshuabeny1999/tolka/internal/transcription/azure"
 	"github.com/joshuabeny1999/tolka/internal/transcription/deepgram"
 	"github.com/joshuabeny1999/tolka/internal/transcription/mock"
+	"github.com/joshuabeny1999/tolka/internal/transcription/reconnect"
+	"github.com/joshuabeny1999/tolka/internal/transcription/replay"
+	"github.com/joshuabeny1999/tolka/internal/transcription/stdio"
+	"github.com/joshuabeny1999/tolka/internal/transcription/wsstream"
 	"github.com/joshuabeny1999/tolka/internal/ws"
 )
 
@@ -33,29 +48,210 @@ func main() {
 
 	// 2. WebSocket Hub
 	hub := ws.NewHub()
+	if cfg.RecordingsDir != "" {
+		hub.EnableRecording(cfg.RecordingsDir)
+	}
+	hub.SetHistoryLimits(ws.HistoryLimits{MaxSegments: cfg.HistoryMaxSegments, MaxAge: cfg.HistoryMaxAge})
+	if cfg.StoreBackend != "" {
+		transcripts, err := store.Open(cfg.StoreBackend, cfg.StorePath)
+		if err != nil {
+			log.Fatal("Could not open transcript store: ", err)
+		}
+		defer transcripts.Close()
+		hub.SetStore(transcripts)
+		log.Printf("Storing transcripts with %s in %s", cfg.StoreBackend, cfg.StorePath)
+	}
+
+	// Configured providers by name, the replay provider streams recordings into them
+	targets := make(map[string]registeredProvider)
+	register := func(info ws.ProviderInfo, factory ws.ServiceFactory) {
+		if info.Configured {
+			targets[info.Name] = registeredProvider{info: info, factory: factory}
+		}
+		hub.RegisterProvider(info, factory)
+	}
+
+	// Register Factories (providers without credentials are only listed)
+	register(ws.ProviderInfo{
+		Name:         "azure",
+		Configured:   cfg.HasAzure(),
+		Capabilities: azure.Capabilities,
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		if err := azure.ValidateOptions(opts); err != nil {
+			return nil, err
+		}
+		return resilient("Azure", azure.Capabilities.AudioFormat, func() (transcription.Service, error) {
+			return azure.New(cfg.AzureAPIKey, cfg.AzureRegion), nil
+		}), nil
+	})
+	register(ws.ProviderInfo{
+		Name:         "azure-native",
+		Configured:   cfg.HasAzure(),
+		Capabilities: azure.Capabilities,
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		if err := azure.ValidateOptions(opts); err != nil {
+			return nil, err
+		}
+		return resilient("Azure", azure.Capabilities.AudioFormat, func() (transcription.Service, error) {
+			return azure.NewNative(azure.NativeConfig{
+				Key:      cfg.AzureAPIKey,
+				Region:   cfg.AzureRegion,
+				Endpoint: cfg.AzureEndpoint,
+			}), nil
+		}), nil
+	})
+	deepgramDefaults, err := deepgram.ParseSettings(cfg.DeepgramOptions)
+	if err != nil {
+		log.Fatal("Invalid Deepgram configuration: ", err)
+	}
+	register(ws.ProviderInfo{
+		Name:         "deepgram",
+		Configured:   cfg.HasDeepgram(),
+		Capabilities: deepgram.Capabilities,
+		Options:      deepgramDefaults.Params(),
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		// The hub has merged the session's overrides with the defaults above
+		settings, err := deepgram.ParseSettings(opts.ProviderOptions["deepgram"])
+		if err != nil {
+			return nil, err
+		}
+		if err := deepgram.ValidateOptions(opts, settings); err != nil {
+			return nil, err
+		}
+		return resilient("Deepgram", deepgram.Capabilities.AudioFormat, func() (transcription.Service, error) {
+			return deepgram.New(cfg.DeepgramAPIKey, settings), nil
+		}), nil
+	})
+	execConfig := stdio.Config{
+		Name:        "Exec",
+		Command:     cfg.ExecCommand,
+		Args:        cfg.ExecArgs,
+		Env:         cfg.ExecEnv,
+		AudioFormat: transcription.AudioFormat(cfg.ExecAudioFormat),
 
-	// Register Factories
-	hub.RegisterProvider("azure", func() transcription.Service {
-		return azure.New(cfg.AzureAPIKey, cfg.AzureRegion)
+		MaxRestarts:   3,
+		RestartWindow: 5 * time.Minute,
+	}
+	register(ws.ProviderInfo{
+		Name:         "exec",
+		Configured:   cfg.HasExec(),
+		Capabilities: execConfig.Capabilities(),
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		return resilient("Exec", execConfig.AudioFormat, func() (transcription.Service, error) {
+			return stdio.New(execConfig), nil
+		}), nil
 	})
-	hub.RegisterProvider("deepgram", func() transcription.Service {
-		return deepgram.New(cfg.DeepgramAPIKey)
+	asrFields, err := wsstream.ParseFieldMapping(cfg.AsrWsFields)
+	if err != nil {
+		log.Fatal("Invalid ASR_WS_FIELDS:", err)
+	}
+	asrConfig := wsstream.Config{
+		Name:        "ASR-WS",
+		URL:         cfg.AsrWsURL,
+		AuthHeader:  cfg.AsrWsAuthHeader,
+		AuthValue:   cfg.AsrWsAuthValue,
+		Fields:      asrFields,
+		AudioFormat: transcription.AudioFormat(cfg.AsrWsAudioFormat),
+	}
+	register(ws.ProviderInfo{
+		Name:         "websocket",
+		Configured:   cfg.HasAsrWs(),
+		Capabilities: asrConfig.Capabilities(),
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		return resilient("ASR-WS", asrConfig.AudioFormat, func() (transcription.Service, error) {
+			return wsstream.New(asrConfig), nil
+		}), nil
 	})
-	hub.RegisterProvider("mock", func() transcription.Service {
-		return mock.New()
+	register(ws.ProviderInfo{
+		Name:         "mock",
+		Configured:   true,
+		Capabilities: mock.Capabilities,
+		// Built-in ("conversation", "code-switching") or file in MOCK_SCRIPTS_DIR, e.g. ?mock.script=standup
+		// Fault injection for tests, e.g. ?mock.error_after=5&mock.error_kind=network
+		Options: mock.Options(),
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		return mock.FromParams(cfg.MockScriptsDir, opts.ProviderOptions["mock"])
+	})
+	// Replays a recording from RECORDINGS_DIR, e.g. ?provider=replay&replay.recording=<room>-deepgram
+	// With replay.target=<provider> the recorded audio is streamed into that provider instead
+	hub.RegisterProvider(ws.ProviderInfo{
+		Name:         "replay",
+		Configured:   cfg.RecordingsDir != "",
+		Capabilities: replay.Capabilities,
+		Options:      map[string]string{"recording": "", "target": "", "speed": "1"},
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		params := opts.ProviderOptions["replay"]
+		rec, err := replay.Find(cfg.RecordingsDir, params["recording"])
+		if err != nil {
+			return nil, err
+		}
+		speed, err := strconv.ParseFloat(params["speed"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replay speed %q", params["speed"])
+		}
+		if params["target"] == "" {
+			return replay.New(rec, speed)
+		}
+
+		target, ok := targets[params["target"]]
+		if !ok {
+			return nil, fmt.Errorf("replay target %s is not available", params["target"])
+		}
+		if target.info.Capabilities.AudioFormat != rec.Format {
+			return nil, fmt.Errorf("replay target %s expects %s audio, the recording holds %s",
+				target.info.Name, target.info.Capabilities.AudioFormat, rec.Format)
+		}
+		// The target is not part of the session, so it runs with its defaults
+		targetOpts := opts
+		targetOpts.ProviderOptions = nil
+		if len(target.info.Options) > 0 {
+			targetOpts.ProviderOptions = map[string]transcription.ProviderParams{target.info.Name: target.info.Options}
+		}
+		inner, err := target.factory(targetOpts)
+		if err != nil {
+			return nil, err
+		}
+		return replay.Stream(rec, inner, speed)
+	})
+
+	// API: List Providers
+	// GET /api/providers
+	mux.HandleFunc("/api/providers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hub.Providers())
 	})
 
 	// 3. API: Create Session
-	// POST /api/session?provider=mock
+	// POST /api/session?provider=mock&providers=azure,azure-native,mock&language=de-CH&languages=de-CH,en-US&auto_detect=true&vocabulary=Tolka,Zürich
+	// All providers of the failover chain must expect the same audio format (GET /api/providers)
+	// Alternatively as JSON body: {"provider": "mock", "language": "de-CH", "languages": ["en-US"], "auto_detect": true, "vocabulary": ["Tolka"]}
+	// Provider options: ?deepgram.endpointing=300 or {"provider_options": {"deepgram": {"endpointing": 300}}}
+	// GET /api/session?room=ID returns the session metadata including the effective options
 	mux.HandleFunc("/api/session", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			info, err := hub.SessionInfo(r.URL.Query().Get("room"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(info)
+			return
+		}
+
 		if r.Method == http.MethodPost {
 
-			provider := r.URL.Query().Get("provider")
-			if provider == "" {
-				provider = "mock" // default
+			req, err := parseSessionRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
 
-			id, err := hub.CreateSession(provider)
+			id, err := hub.CreateSession(req.Providers, req.Options)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
@@ -89,6 +285,71 @@ func main() {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	})
 
+	// API: Download stored audio (sessions created with audio_consent=true)
+	// GET /api/session/audio?room=ID&part=1
+	// A new WebM stream, e.g. after the host reconnected, starts a new part
+	mux.HandleFunc("/api/session/audio", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		part := 1
+		if value := r.URL.Query().Get("part"); value != "" {
+			var err error
+			if part, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid part", http.StatusBadRequest)
+				return
+			}
+		}
+
+		roomID := r.URL.Query().Get("room")
+		path, err := hub.AudioFile(roomID, part)
+		if errors.Is(err, ws.ErrRecordingActive) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		ext := filepath.Ext(path)
+		w.Header().Set("Content-Type", audiofile.ContentType(ext))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+		http.ServeFile(w, r, path)
+	})
+
+	// API: Export the final segments of a session with the assigned speaker names
+	// GET /api/session/export?room=ID&format=srt|vtt|txt|md|json|eaf|textgrid
+	mux.HandleFunc("/api/session/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if export.ContentType(format) == "" {
+			http.Error(w, fmt.Sprintf("Invalid format, expected one of %s", strings.Join(export.Formats(), ", ")), http.StatusBadRequest)
+			return
+		}
+
+		roomID := r.URL.Query().Get("room")
+		transcript, err := hub.Transcript(roomID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		// Render first, so a failure can still be reported with a status code
+		var buf bytes.Buffer
+		if err := export.Render(&buf, format, transcript); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "tolka-"+transcript.Session.ID+export.Extension(format)))
+		w.Write(buf.Bytes())
+	})
+
 	// 4. WebSocket Endpoint
 	mux.Handle("/ws/connect", hub)
 
@@ -114,3 +375,104 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// registeredProvider is a provider as registered with the hub.
+type registeredProvider struct {
+	info    ws.ProviderInfo
+	factory ws.ServiceFactory
+}
+
+// resilient wraps a provider so short network outages are bridged by reconnecting.
+// WebM streams need their header chunk again after a reconnect. Workers that used up
+// their restart budget report a permanent error, the session then fails over.
+func resilient(name string, format transcription.AudioFormat, factory reconnect.Factory) transcription.Service {
+	rcfg := reconnect.DefaultConfig
+	rcfg.Name = name
+	rcfg.ReplayHeader = format == transcription.AudioWebM
+	return reconnect.New(factory, rcfg)
+}
+
+// sessionRequest holds the parameters of POST /api/session.
+type sessionRequest struct {
+	Provider string `json:"provider"`
+	// Providers is an ordered failover chain and takes precedence over Provider.
+	Providers []string `json:"providers"`
+	transcription.Options
+}
+
+// parseSessionRequest reads the optional JSON body and lets query parameters override it.
+func parseSessionRequest(r *http.Request) (sessionRequest, error) {
+	var req sessionRequest
+
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			return req, fmt.Errorf("invalid request body: %w", err)
+		}
+	}
+
+	q := r.URL.Query()
+	if provider := q.Get("provider"); provider != "" {
+		req.Provider = provider
+	}
+	if providers := q.Get("providers"); providers != "" {
+		req.Providers = splitList(providers)
+	}
+	if language := q.Get("language"); language != "" {
+		req.Language = language
+	}
+	if languages := q.Get("languages"); languages != "" {
+		req.Languages = splitList(languages)
+	}
+	if vocabulary := q.Get("vocabulary"); vocabulary != "" {
+		req.Vocabulary = splitList(vocabulary)
+	}
+	for key, target := range map[string]*bool{
+		"auto_detect":   &req.AutoDetect,
+		"audio_consent": &req.AudioConsent,
+		"record":        &req.Record,
+	} {
+		value := q.Get(key)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return req, fmt.Errorf("invalid %s value %q", key, value)
+		}
+		*target = enabled
+	}
+
+	// Provider options as "<provider>.<option>=value"
+	for key, values := range q {
+		provider, option, ok := strings.Cut(key, ".")
+		if !ok || provider == "" || option == "" {
+			continue
+		}
+		if req.ProviderOptions == nil {
+			req.ProviderOptions = make(map[string]transcription.ProviderParams)
+		}
+		if req.ProviderOptions[provider] == nil {
+			req.ProviderOptions[provider] = make(transcription.ProviderParams)
+		}
+		req.ProviderOptions[provider][option] = values[0]
+	}
+
+	if req.Provider == "" {
+		req.Provider = "mock" // default
+	}
+	if len(req.Providers) == 0 {
+		req.Providers = []string{req.Provider}
+	}
+	return req, nil
+}
+
+// splitList splits a comma separated query value and drops empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
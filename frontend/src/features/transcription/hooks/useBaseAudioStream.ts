@@ -26,6 +26,37 @@ export const useBaseAudioStream = (wsUrl: string) => {
     const handleMessage = useCallback((event: MessageEvent) => {
         try {
             const data = JSON.parse(event.data);
+
+            // Verlauf beim (Wieder-)Verbinden: ersetzt die bisherigen Segmente
+            if (data.type === 'history') {
+                const history: TranscriptSegment[] = (data.payload?.segments || [])
+                    .filter((s: { text?: string }) => s.text && s.text.trim())
+                    .map((s: { utterance_id?: string; text: string; speaker?: string; received_at?: string }) => ({
+                        id: s.utterance_id || crypto.randomUUID(),
+                        text: s.text.trim(),
+                        speaker: s.speaker?.trim() || 'Unknown',
+                        timestamp: s.received_at ? Date.parse(s.received_at) : Date.now(),
+                        isFinal: true
+                    }));
+                setSegments(history);
+                lastCommittedSegmentRef.current = history.length ? history[history.length - 1].id : null;
+                return;
+            }
+
+            // Korrektur des Hosts: Text ändern, Sprecher neu zuweisen oder Segment löschen
+            if (data.type === 'segment_edit') {
+                const edit: { utterance_id: string; action: string; text?: string; speaker?: string } = data.payload;
+                setSegments(prev => edit.action === 'delete'
+                    ? prev.filter(s => s.id !== edit.utterance_id)
+                    : prev.map(s => {
+                        if (s.id !== edit.utterance_id) return s;
+                        if (edit.action === 'text' && edit.text) return { ...s, text: edit.text.trim() };
+                        if (edit.action === 'speaker' && edit.speaker) return { ...s, speaker: edit.speaker };
+                        return s;
+                    }));
+                return;
+            }
+
             const payload = data.payload || data;
             if (!payload.text) return;
 
@@ -36,12 +67,13 @@ export const useBaseAudioStream = (wsUrl: string) => {
                 setPartialText(trimmedText);
                 if (payload.speaker) setPartialSpeaker(payload.speaker);
             } else {
-                // De-bounce check (wichtig für Azure, schadet den anderen nicht)
-                if (lastCommittedSegmentRef.current === trimmedText) return;
-                lastCommittedSegmentRef.current = trimmedText;
+                // De-bounce check: Server vergibt pro Äusserung eine stabile ID
+                const segmentKey = payload.utterance_id || trimmedText;
+                if (lastCommittedSegmentRef.current === segmentKey) return;
+                lastCommittedSegmentRef.current = segmentKey;
 
                 const newSegment: TranscriptSegment = {
-                    id: crypto.randomUUID(),
+                    id: payload.utterance_id || crypto.randomUUID(),
                     text: trimmedText,
                     speaker: payload.speaker.trim() || 'Unknown',
                     timestamp: Date.now(),
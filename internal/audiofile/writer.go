@@ -0,0 +1,133 @@
+// Package audiofile stores the host's audio on disk. PCM is written as WAV,
+// container formats like WebM are stored as received.
+package audiofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+const wavHeaderSize = 44
+
+// Extension returns the file extension used for audio in the given format.
+func Extension(format transcription.AudioFormat) string {
+	switch format {
+	case transcription.AudioPCM16:
+		return ".wav"
+	case transcription.AudioWebM:
+		return ".webm"
+	default:
+		return ".raw" // Unknown encoding, bytes as received
+	}
+}
+
+// Extensions lists all extensions Extension can return.
+var Extensions = []string{".wav", ".webm", ".raw"}
+
+// ContentType returns the MIME type for a file extension returned by Extension.
+func ContentType(ext string) string {
+	switch ext {
+	case ".wav":
+		return "audio/wav"
+	case ".webm":
+		return "audio/webm"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// webmMagic is the EBML header every WebM stream starts with.
+var webmMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// IsWebMStart reports whether data starts a new WebM stream.
+func IsWebMStart(data []byte) bool {
+	return bytes.HasPrefix(data, webmMagic)
+}
+
+// Detect returns the format of a stream starting with data. The host sends what
+// its browser records, which is not necessarily the format the provider declares.
+// PCM has no header, so declared PCM is trusted.
+func Detect(data []byte, declared transcription.AudioFormat) transcription.AudioFormat {
+	switch {
+	case IsWebMStart(data):
+		return transcription.AudioWebM
+	case declared == transcription.AudioWebM:
+		return "" // Kein WebM, Bytes wie empfangen
+	default:
+		return declared
+	}
+}
+
+// Writer writes audio chunks to a file. Close must be called to finish WAV files,
+// their header holds the final size.
+type Writer struct {
+	f      *os.File
+	format transcription.AudioFormat
+	size   int64
+}
+
+// Create creates base + Extension(format). Existing files are never overwritten.
+func Create(base string, format transcription.AudioFormat) (*Writer, error) {
+	f, err := os.OpenFile(base+Extension(format), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{f: f, format: format}
+	if format == transcription.AudioPCM16 {
+		if _, err := f.Write(wavHeader(0)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	n, err := w.f.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Size returns the number of audio bytes written, without headers.
+func (w *Writer) Size() int64 { return w.size }
+
+// Name returns the path of the file.
+func (w *Writer) Name() string { return w.f.Name() }
+
+// Close finishes the WAV header and closes the file.
+func (w *Writer) Close() error {
+	if w.format == transcription.AudioPCM16 {
+		if _, err := w.f.WriteAt(wavHeader(w.size), 0); err != nil {
+			w.f.Close()
+			return err
+		}
+	}
+	return w.f.Close()
+}
+
+// wavHeader describes size bytes of 16 kHz mono 16-bit PCM.
+func wavHeader(size int64) []byte {
+	const (
+		sampleRate    = 16000
+		bitsPerSample = 16
+		channels      = 1
+	)
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(wavHeaderSize-8+size))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	return buf.Bytes()
+}
@@ -0,0 +1,79 @@
+package audiofile_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joshuabeny1999/tolka/internal/audiofile"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+func TestWriter_WAV(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "session")
+	w, err := audiofile.Create(base, transcription.AudioPCM16)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write(make([]byte, 320))
+	w.Write(make([]byte, 320))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(base + ".wav")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != 44+640 || string(data[:4]) != "RIFF" || string(data[36:40]) != "data" {
+		t.Fatalf("Unexpected WAV file of %d bytes", len(data))
+	}
+	if size := binary.LittleEndian.Uint32(data[4:8]); size != 36+640 {
+		t.Errorf("Expected RIFF size %d, got %d", 36+640, size)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:44]); size != 640 {
+		t.Errorf("Expected data size 640, got %d", size)
+	}
+
+	// Bestehende Aufnahmen werden nie überschrieben
+	if _, err := audiofile.Create(base, transcription.AudioPCM16); err == nil {
+		t.Error("Expected error for existing file")
+	}
+}
+
+func TestWriter_Container(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "session")
+	w, err := audiofile.Create(base, transcription.AudioWebM)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write([]byte("webm-chunk"))
+	w.Close()
+
+	data, err := os.ReadFile(base + ".webm")
+	if err != nil || string(data) != "webm-chunk" {
+		t.Errorf("Expected chunk stored as received, got %q (%v)", data, err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	webm := []byte{0x1a, 0x45, 0xdf, 0xa3, 0x01}
+	tests := []struct {
+		data     []byte
+		declared transcription.AudioFormat
+		want     transcription.AudioFormat
+	}{
+		{webm, transcription.AudioWebM, transcription.AudioWebM},
+		{webm, "", transcription.AudioWebM},
+		{webm, transcription.AudioPCM16, transcription.AudioWebM},
+		{make([]byte, 320), transcription.AudioPCM16, transcription.AudioPCM16},
+		{make([]byte, 320), transcription.AudioWebM, ""},
+		{[]byte("fake-audio-data"), "", ""},
+	}
+	for _, tt := range tests {
+		if got := audiofile.Detect(tt.data, tt.declared); got != tt.want {
+			t.Errorf("Detect(%x, %q) = %q, want %q", tt.data[:4], tt.declared, got, tt.want)
+		}
+	}
+}
@@ -3,18 +3,51 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	DeepgramAPIKey string
-	AzureAPIKey    string
-	AzureRegion    string
-	Port           string
-	AuthUsername   string
-	AuthPassword   string
-	WsToken        string
+	// DeepgramOptions holds the deployment defaults (model, endpointing, ...), see deepgram.ParseSettings
+	DeepgramOptions map[string]string
+	AzureAPIKey     string
+	AzureRegion     string
+	AzureEndpoint   string
+	Port            string
+	AuthUsername    string
+	AuthPassword    string
+	WsToken         string
+
+	// Generic stdio engine (whisper.cpp, Vosk, ...), see transcription/stdio
+	ExecCommand     string
+	ExecArgs        []string
+	ExecEnv         []string
+	ExecAudioFormat string
+
+	// Self-hosted streaming ASR server, see transcription/wsstream
+	AsrWsURL         string
+	AsrWsAuthHeader  string
+	AsrWsAuthValue   string
+	AsrWsFields      string
+	AsrWsAudioFormat string
+
+	// Directory with mock scripts (JSON, YAML, SRT), selectable per session
+	MockScriptsDir string
+
+	// Directory for session recordings, empty disables recording and replay
+	RecordingsDir string
+
+	// Transcript history replayed to late joiners, zero disables a bound
+	HistoryMaxSegments int
+	HistoryMaxAge      time.Duration
+
+	// Transcript store: "jsonl" (directory) or "sqlite" (database file), empty keeps nothing
+	StoreBackend string
+	StorePath    string
 }
 
 func Load() *Config {
@@ -37,13 +70,121 @@ func Load() *Config {
 	azureApiKey := os.Getenv("AZURE_API_KEY")
 	azureRegion := os.Getenv("AZURE_REGION")
 
+	execAudioFormat := os.Getenv("EXEC_AUDIO_FORMAT")
+	if execAudioFormat == "" {
+		execAudioFormat = "pcm_s16le_16k"
+	}
+
+	asrWsAuthHeader := os.Getenv("ASR_WS_AUTH_HEADER")
+	if asrWsAuthHeader == "" {
+		asrWsAuthHeader = "Authorization"
+	}
+	asrWsAudioFormat := os.Getenv("ASR_WS_AUDIO_FORMAT")
+	if asrWsAudioFormat == "" {
+		asrWsAudioFormat = "pcm_s16le_16k"
+	}
+
+	deepgramOptions := make(map[string]string)
+	for key, env := range map[string]string{
+		"model":         "DEEPGRAM_MODEL",
+		"endpointing":   "DEEPGRAM_ENDPOINTING",
+		"utterance_end": "DEEPGRAM_UTTERANCE_END",
+		"smart_format":  "DEEPGRAM_SMART_FORMAT",
+		"diarize":       "DEEPGRAM_DIARIZE",
+	} {
+		if value := os.Getenv(env); value != "" {
+			deepgramOptions[key] = value
+		}
+	}
+
 	return &Config{
-		DeepgramAPIKey: apiKey,
-		AzureAPIKey:    azureApiKey,
-		AzureRegion:    azureRegion,
-		Port:           port,
-		AuthUsername:   authUsername,
-		AuthPassword:   authPassword,
-		WsToken:        wsToken,
+		DeepgramAPIKey:  apiKey,
+		DeepgramOptions: deepgramOptions,
+		AzureAPIKey:     azureApiKey,
+		AzureRegion:     azureRegion,
+		AzureEndpoint:   os.Getenv("AZURE_ENDPOINT"),
+		Port:            port,
+		AuthUsername:    authUsername,
+		AuthPassword:    authPassword,
+		WsToken:         wsToken,
+
+		ExecCommand:     os.Getenv("EXEC_COMMAND"),
+		ExecArgs:        strings.Fields(os.Getenv("EXEC_ARGS")),
+		ExecEnv:         splitList(os.Getenv("EXEC_ENV")),
+		ExecAudioFormat: execAudioFormat,
+
+		AsrWsURL:         os.Getenv("ASR_WS_URL"),
+		AsrWsAuthHeader:  asrWsAuthHeader,
+		AsrWsAuthValue:   os.Getenv("ASR_WS_AUTH_VALUE"),
+		AsrWsFields:      os.Getenv("ASR_WS_FIELDS"),
+		AsrWsAudioFormat: asrWsAudioFormat,
+
+		MockScriptsDir: os.Getenv("MOCK_SCRIPTS_DIR"),
+		RecordingsDir:  os.Getenv("RECORDINGS_DIR"),
+
+		HistoryMaxSegments: envInt("HISTORY_MAX_SEGMENTS", 500),
+		HistoryMaxAge:      envDuration("HISTORY_MAX_AGE", 0),
+
+		StoreBackend: os.Getenv("STORE_BACKEND"),
+		StorePath:    os.Getenv("STORE_PATH"),
+	}
+}
+
+// HasDeepgram reports whether Deepgram credentials are configured.
+func (c *Config) HasDeepgram() bool {
+	return c.DeepgramAPIKey != ""
+}
+
+// HasAzure reports whether Azure Speech credentials are configured.
+func (c *Config) HasAzure() bool {
+	return c.AzureAPIKey != "" && c.AzureRegion != ""
+}
+
+// HasExec reports whether a stdio engine command is configured.
+func (c *Config) HasExec() bool {
+	return c.ExecCommand != ""
+}
+
+// HasAsrWs reports whether a streaming ASR server is configured.
+func (c *Config) HasAsrWs() bool {
+	return c.AsrWsURL != ""
+}
+
+// envInt reads an integer, invalid values fall back to def.
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Warning: Invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a duration like "90m", invalid values fall back to def.
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Warning: Invalid %s %q, using %v", key, value, def)
+		return def
+	}
+	return d
+}
+
+// splitList splits a comma separated value and drops empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -12,6 +13,8 @@ func TestLoad_Defaults(t *testing.T) {
 	t.Setenv("AUTH_USERNAME", "")
 	t.Setenv("AUTH_PASSWORD", "")
 	t.Setenv("WS_TOKEN", "")
+	t.Setenv("HISTORY_MAX_SEGMENTS", "")
+	t.Setenv("HISTORY_MAX_AGE", "")
 
 	// 2. Execution
 	cfg := Load()
@@ -36,6 +39,10 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.WsToken != "" {
 		t.Errorf("Expected empty WS token, got '%s'", cfg.WsToken)
 	}
+
+	if cfg.HistoryMaxSegments != 500 || cfg.HistoryMaxAge != 0 {
+		t.Errorf("Expected history of 500 segments without age limit, got %d / %v", cfg.HistoryMaxSegments, cfg.HistoryMaxAge)
+	}
 }
 
 func TestLoad_Overrides(t *testing.T) {
@@ -51,6 +58,9 @@ func TestLoad_Overrides(t *testing.T) {
 	t.Setenv("AUTH_USERNAME", expectedUsername)
 	t.Setenv("AUTH_PASSWORD", expectedPassword)
 	t.Setenv("WS_TOKEN", expectedWsToken)
+	t.Setenv("DEEPGRAM_ENDPOINTING", "300")
+	t.Setenv("HISTORY_MAX_SEGMENTS", "many")
+	t.Setenv("HISTORY_MAX_AGE", "90m")
 
 	// 2. Execution
 	cfg := Load()
@@ -73,4 +83,26 @@ func TestLoad_Overrides(t *testing.T) {
 	if cfg.WsToken != expectedWsToken {
 		t.Errorf("Expected WS token '%s', got '%s'", expectedWsToken, cfg.WsToken)
 	}
+	if len(cfg.DeepgramOptions) != 1 || cfg.DeepgramOptions["endpointing"] != "300" {
+		t.Errorf("Expected only endpointing in Deepgram options, got %v", cfg.DeepgramOptions)
+	}
+	if cfg.HistoryMaxSegments != 500 || cfg.HistoryMaxAge != 90*time.Minute {
+		t.Errorf("Expected default segments and 90m age, got %d / %v", cfg.HistoryMaxSegments, cfg.HistoryMaxAge)
+	}
+}
+
+func TestConfig_ProviderCredentials(t *testing.T) {
+	cfg := &Config{DeepgramAPIKey: "dg-key", AzureAPIKey: "az-key"}
+
+	if !cfg.HasDeepgram() {
+		t.Error("Expected Deepgram to be configured")
+	}
+	if cfg.HasAzure() {
+		t.Error("Expected Azure without region to be unconfigured")
+	}
+
+	cfg.AzureRegion = "switzerlandnorth"
+	if !cfg.HasAzure() {
+		t.Error("Expected Azure to be configured")
+	}
 }
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ELAN annotation format, see https://www.mpi.nl/tools/elan/EAF_Annotation_Format_3.0_and_ELAN.pdf
+type eafDocument struct {
+	XMLName     xml.Name            `xml:"ANNOTATION_DOCUMENT"`
+	Author      string              `xml:"AUTHOR,attr"`
+	Date        string              `xml:"DATE,attr"`
+	Format      string              `xml:"FORMAT,attr"`
+	Version     string              `xml:"VERSION,attr"`
+	XSI         string              `xml:"xmlns:xsi,attr"`
+	Schema      string              `xml:"xsi:noNamespaceSchemaLocation,attr"`
+	Header      eafHeader           `xml:"HEADER"`
+	TimeOrder   eafTimeOrder        `xml:"TIME_ORDER"` // Required, even when empty
+	Tiers       []eafTier           `xml:"TIER"`
+	Types       []eafLinguisticType `xml:"LINGUISTIC_TYPE"`
+	Constraints []eafConstraint     `xml:"CONSTRAINT"`
+}
+
+type eafHeader struct {
+	MediaFile string `xml:"MEDIA_FILE,attr"`
+	TimeUnits string `xml:"TIME_UNITS,attr"`
+}
+
+type eafTimeOrder struct {
+	Slots []eafTimeSlot `xml:"TIME_SLOT"`
+}
+
+type eafTimeSlot struct {
+	ID    string `xml:"TIME_SLOT_ID,attr"`
+	Value int64  `xml:"TIME_VALUE,attr"`
+}
+
+type eafTier struct {
+	ID          string          `xml:"TIER_ID,attr"`
+	Participant string          `xml:"PARTICIPANT,attr,omitempty"`
+	Type        string          `xml:"LINGUISTIC_TYPE_REF,attr"`
+	Parent      string          `xml:"PARENT_REF,attr,omitempty"`
+	Annotations []eafAnnotation `xml:"ANNOTATION"`
+}
+
+type eafAnnotation struct {
+	Alignable eafAlignable `xml:"ALIGNABLE_ANNOTATION"`
+}
+
+type eafAlignable struct {
+	ID    string `xml:"ANNOTATION_ID,attr"`
+	Start string `xml:"TIME_SLOT_REF1,attr"`
+	End   string `xml:"TIME_SLOT_REF2,attr"`
+	Value string `xml:"ANNOTATION_VALUE"`
+}
+
+type eafLinguisticType struct {
+	ID            string `xml:"LINGUISTIC_TYPE_ID,attr"`
+	TimeAlignable bool   `xml:"TIME_ALIGNABLE,attr"`
+	Graphic       bool   `xml:"GRAPHIC_REFERENCES,attr"`
+	Constraints   string `xml:"CONSTRAINTS,attr,omitempty"`
+}
+
+type eafConstraint struct {
+	Stereotype  string `xml:"STEREOTYPE,attr"`
+	Description string `xml:"DESCRIPTION,attr"`
+}
+
+// eafBuilder numbers time slots and annotations across all tiers.
+type eafBuilder struct {
+	doc         eafDocument
+	annotations int
+}
+
+func (b *eafBuilder) slot(seconds float64) string {
+	id := fmt.Sprintf("ts%d", len(b.doc.TimeOrder.Slots)+1)
+	b.doc.TimeOrder.Slots = append(b.doc.TimeOrder.Slots, eafTimeSlot{ID: id, Value: int64(math.Round(seconds * 1000))})
+	return id
+}
+
+func (b *eafBuilder) annotate(iv interval) eafAnnotation {
+	b.annotations++
+	return eafAnnotation{Alignable: eafAlignable{
+		ID:    fmt.Sprintf("a%d", b.annotations),
+		Start: b.slot(iv.start),
+		End:   b.slot(iv.end),
+		Value: iv.text,
+	}}
+}
+
+// renderEAF writes one utterance tier per speaker. Word timings go to a child tier,
+// so the words stay aligned when researchers move the utterance boundaries.
+func renderEAF(w io.Writer, t Transcript) error {
+	created := t.Session.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	b := eafBuilder{doc: eafDocument{
+		Author:  "Tolka",
+		Date:    created.Format(time.RFC3339),
+		Format:  "3.0",
+		Version: "3.0",
+		XSI:     "http://www.w3.org/2001/XMLSchema-instance",
+		Schema:  "http://www.mpi.nl/tools/elan/EAFv3.0.xsd",
+		Header:  eafHeader{TimeUnits: "milliseconds"},
+		Types: []eafLinguisticType{
+			{ID: "utterance", TimeAlignable: true},
+			{ID: "word", TimeAlignable: true, Constraints: "Included_In"},
+		},
+		Constraints: []eafConstraint{{
+			Stereotype:  "Included_In",
+			Description: "Time alignable annotations within the parent annotation's time interval, gaps are allowed",
+		}},
+	}}
+
+	for _, tr := range t.tiers() {
+		utterances := eafTier{ID: tr.name, Participant: tr.name, Type: "utterance"}
+		words := eafTier{ID: wordTierName(tr.name), Participant: tr.name, Type: "word", Parent: tr.name}
+		for _, s := range tr.spans {
+			utterances.Annotations = append(utterances.Annotations, b.annotate(s.interval))
+			for _, word := range s.words {
+				words.Annotations = append(words.Annotations, b.annotate(word))
+			}
+		}
+		b.doc.Tiers = append(b.doc.Tiers, utterances)
+		if tr.hasWords() {
+			b.doc.Tiers = append(b.doc.Tiers, words)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "    ")
+	if err := enc.Encode(b.doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,127 @@
+// Package export renders the final segments of a session as subtitles, text, JSON or
+// annotation tiers for ELAN and Praat.
+// Speaker labels of the provider are replaced by the names the host assigned.
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/store"
+)
+
+// Formats
+const (
+	FormatSRT      = "srt"
+	FormatVTT      = "vtt"
+	FormatText     = "txt"
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatEAF      = "eaf"
+	FormatTextGrid = "textgrid"
+)
+
+// fallbackDuration is used for segments without timing information.
+const fallbackDuration = 2.0
+
+// Transcript is a session with its speakers and final segments in order.
+type Transcript struct {
+	Session  store.Session
+	Speakers map[string]store.Speaker
+	Segments []store.Segment
+}
+
+type renderer struct {
+	contentType string
+	extension   string
+	render      func(io.Writer, Transcript) error
+}
+
+var renderers = map[string]renderer{
+	FormatSRT:      {"application/x-subrip; charset=utf-8", ".srt", renderSRT},
+	FormatVTT:      {"text/vtt; charset=utf-8", ".vtt", renderVTT},
+	FormatText:     {"text/plain; charset=utf-8", ".txt", renderText},
+	FormatMarkdown: {"text/markdown; charset=utf-8", ".md", renderMarkdown},
+	FormatJSON:     {"application/json", ".json", renderJSON},
+	FormatEAF:      {"application/xml; charset=utf-8", ".eaf", renderEAF},
+	FormatTextGrid: {"text/plain; charset=utf-8", ".TextGrid", renderTextGrid},
+}
+
+// Formats returns the supported formats, sorted.
+func Formats() []string {
+	formats := make([]string, 0, len(renderers))
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ContentType returns the MIME type of a format, empty for unknown formats.
+func ContentType(format string) string {
+	return renderers[format].contentType
+}
+
+// Extension returns the file extension of a format, including the dot.
+func Extension(format string) string {
+	return renderers[format].extension
+}
+
+// Render writes the transcript in the given format.
+func Render(w io.Writer, format string, t Transcript) error {
+	r, ok := renderers[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %q (%s)", format, strings.Join(Formats(), ", "))
+	}
+	return r.render(w, t)
+}
+
+// SpeakerName returns the name the host assigned, the provider's label otherwise.
+func (t Transcript) SpeakerName(id string) string {
+	if speaker, ok := t.Speakers[id]; ok && speaker.Name != "" {
+		return speaker.Name
+	}
+	if id == "" {
+		return "Unknown"
+	}
+	return id
+}
+
+// cue is a segment with the timing used for subtitles.
+type cue struct {
+	store.Segment
+	start, end float64
+}
+
+// cues returns the segments with their timing. Segments without timing are placed
+// by the time the room received them, relative to the first segment.
+func (t Transcript) cues() []cue {
+	cues := make([]cue, 0, len(t.Segments))
+	var origin time.Time
+	for _, segment := range t.Segments {
+		if origin.IsZero() {
+			origin = segment.ReceivedAt
+		}
+		c := cue{Segment: segment, start: segment.Start, end: segment.End}
+		if c.end <= c.start {
+			c.start = max(segment.ReceivedAt.Sub(origin).Seconds(), 0)
+			c.end = c.start + fallbackDuration
+		}
+		cues = append(cues, c)
+	}
+	return cues
+}
+
+// timestamp formats seconds as HH:MM:SS followed by sep and milliseconds.
+func timestamp(seconds float64, sep string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// clock formats seconds as HH:MM:SS.
+func clock(seconds float64) string {
+	return timestamp(seconds, ",")[:8]
+}
@@ -0,0 +1,210 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/store"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+func testTranscript() Transcript {
+	received := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	return Transcript{
+		Session:  store.Session{ID: "room1", Provider: "mock", Created: received},
+		Speakers: map[string]store.Speaker{"Speaker 1": {Name: "Anna"}, "Speaker 2": {Position: 90}},
+		Segments: []store.Segment{
+			{TranscriptResult: transcription.TranscriptResult{Text: "Guten Morgen", Speaker: "Speaker 1", Start: 1.5, End: 3.25, UtteranceID: "u1"}, ReceivedAt: received},
+			{TranscriptResult: transcription.TranscriptResult{Text: "Wie geht's\ndir?", Speaker: "Speaker 1", Start: 3.5, End: 4}, ReceivedAt: received.Add(3 * time.Second)},
+			{TranscriptResult: transcription.TranscriptResult{Text: "Gut <danke>", Speaker: "Speaker 2", Start: 3661, End: 3662.5}, ReceivedAt: received.Add(time.Hour)},
+			// Ohne Zeitangaben des Providers
+			{TranscriptResult: transcription.TranscriptResult{Text: "Tschüss"}, ReceivedAt: received.Add(10 * time.Second)},
+		},
+	}
+}
+
+func render(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Render(&buf, format, testTranscript()); err != nil {
+		t.Fatalf("Render %s failed: %v", format, err)
+	}
+	return buf.String()
+}
+
+func TestRender_SRT(t *testing.T) {
+	out := render(t, FormatSRT)
+	for _, want := range []string{
+		"1\n00:00:01,500 --> 00:00:03,250\nAnna: Guten Morgen\n\n",
+		"2\n00:00:03,500 --> 00:00:04,000\nAnna: Wie geht's dir?\n\n",
+		"3\n01:01:01,000 --> 01:01:02,500\nSpeaker 2: Gut <danke>\n\n",
+		"4\n00:00:10,000 --> 00:00:12,000\nUnknown: Tschüss\n\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRender_VTT(t *testing.T) {
+	out := render(t, FormatVTT)
+	if !strings.HasPrefix(out, "WEBVTT\n\n") {
+		t.Errorf("Expected WEBVTT header, got:\n%s", out)
+	}
+	for _, want := range []string{
+		"00:00:01.500 --> 00:00:03.250\n<v Anna>Guten Morgen\n",
+		"<v Speaker 2>Gut &lt;danke&gt;\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRender_TextAndMarkdown(t *testing.T) {
+	text := render(t, FormatText)
+	if !strings.HasPrefix(text, "[00:00:01] Anna: Guten Morgen\n[00:00:03] Anna: Wie geht's dir?\n") {
+		t.Errorf("Unexpected text export:\n%s", text)
+	}
+
+	md := render(t, FormatMarkdown)
+	// Aufeinanderfolgende Segmente derselben Person werden zusammengefasst
+	for _, want := range []string{
+		"# Transkript",
+		"\n**Anna** (00:00:01): Guten Morgen Wie geht's dir?\n",
+		"\n**Speaker 2** (01:01:01): Gut <danke>\n",
+		"\n**Unknown** (00:00:10): Tschüss\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected %q in:\n%s", want, md)
+		}
+	}
+}
+
+func TestRender_JSON(t *testing.T) {
+	var out jsonTranscript
+	if err := json.Unmarshal([]byte(render(t, FormatJSON)), &out); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if out.Session.ID != "room1" || len(out.Segments) != 4 {
+		t.Fatalf("Unexpected export: %+v", out)
+	}
+	first := out.Segments[0]
+	if first.SpeakerName != "Anna" || first.Speaker != "Speaker 1" || first.ID != "u1" || first.Start != 1.5 || first.End != 3.25 {
+		t.Errorf("Unexpected segment: %+v", first)
+	}
+	if out.Segments[3].Start != 10 || out.Segments[3].End != 12 {
+		t.Errorf("Expected fallback timing, got %+v", out.Segments[3])
+	}
+}
+
+func TestRender_Invalid(t *testing.T) {
+	if err := Render(&bytes.Buffer{}, "docx", testTranscript()); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if ContentType("docx") != "" || ContentType(FormatVTT) == "" {
+		t.Error("Unexpected content types")
+	}
+
+	// Leere Transkripte sind gültig
+	var buf bytes.Buffer
+	if err := Render(&buf, FormatSRT, Transcript{}); err != nil || buf.Len() != 0 {
+		t.Errorf("Expected empty SRT, got %q (%v)", buf.String(), err)
+	}
+}
+
+// wordTranscript has word timings, overlapping utterances and two speakers with the same name.
+func wordTranscript() Transcript {
+	words := []transcription.Word{{Text: "Guten", Start: 1, End: 1.4}, {Text: "Morgen", Start: 1.5, End: 2.2}}
+	return Transcript{
+		Speakers: map[string]store.Speaker{"Speaker 1": {Name: "Anna"}, "Speaker 2": {Name: "Anna"}},
+		Segments: []store.Segment{
+			{TranscriptResult: transcription.TranscriptResult{Text: "Guten Morgen", Speaker: "Speaker 1", Start: 1, End: 2.2, Words: words}},
+			{TranscriptResult: transcription.TranscriptResult{Text: `Sag "Hallo"`, Speaker: "Speaker 1", Start: 2, End: 3}},
+			{TranscriptResult: transcription.TranscriptResult{Text: "Hallo", Speaker: "Speaker 2", Start: 2.5, End: 3.5}},
+		},
+	}
+}
+
+func TestTiers(t *testing.T) {
+	tiers := wordTranscript().tiers()
+	if len(tiers) != 2 || tiers[0].name != "Anna" || tiers[1].name != "Anna (Speaker 2)" {
+		t.Fatalf("Expected unique tier names, got %+v", tiers)
+	}
+	spans := tiers[0].spans
+	if len(spans) != 2 || spans[1].start != 2.2 || len(spans[0].words) != 2 {
+		t.Errorf("Expected overlapping utterance to start after the previous one, got %+v", spans)
+	}
+	if !tiers[0].hasWords() || tiers[1].hasWords() {
+		t.Error("Expected words only on the first tier")
+	}
+}
+
+func TestTiers_CoveredUtterance(t *testing.T) {
+	// Die zweite Äusserung liegt ganz in der ersten und darf nicht verloren gehen
+	transcript := Transcript{Segments: []store.Segment{
+		{TranscriptResult: transcription.TranscriptResult{Text: "Lang", Speaker: "Speaker 1", Start: 1, End: 5}},
+		{TranscriptResult: transcription.TranscriptResult{Text: "Kurz", Speaker: "Speaker 1", Start: 2, End: 3}},
+		{TranscriptResult: transcription.TranscriptResult{Text: "Danach", Speaker: "Speaker 1", Start: 5, End: 6}},
+	}}
+	spans := transcript.tiers()[0].spans
+	if len(spans) != 3 {
+		t.Fatalf("Expected all utterances, got %+v", spans)
+	}
+	if spans[1].text != "Kurz" || spans[1].start != 5 || spans[1].end != 5.001 {
+		t.Errorf("Expected covered utterance after the first one, got %+v", spans[1])
+	}
+	if spans[2].start != 5.001 || spans[2].end != 6 {
+		t.Errorf("Expected following utterance to start after it, got %+v", spans[2])
+	}
+}
+
+func TestRender_EAF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, FormatEAF, wordTranscript()); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	var doc eafDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+	if len(doc.Tiers) != 3 || doc.Tiers[1].ID != "Anna (Wörter)" || doc.Tiers[1].Parent != "Anna" {
+		t.Fatalf("Expected utterance and word tiers, got %+v", doc.Tiers)
+	}
+
+	slots := make(map[string]int64)
+	for _, slot := range doc.TimeOrder.Slots {
+		slots[slot.ID] = slot.Value
+	}
+	word := doc.Tiers[1].Annotations[1].Alignable
+	if word.Value != "Morgen" || slots[word.Start] != 1500 || slots[word.End] != 2200 {
+		t.Errorf("Unexpected word annotation: %+v", word)
+	}
+	if !strings.Contains(buf.String(), `TIME_UNITS="milliseconds"`) {
+		t.Error("Expected time units in header")
+	}
+}
+
+func TestRender_TextGrid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, FormatTextGrid, wordTranscript()); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"File type = \"ooTextFile\"\nObject class = \"TextGrid\"\n\nxmin = 0\nxmax = 3.5\ntiers? <exists>\nsize = 3\n",
+		"        name = \"Anna (Wörter)\"\n",
+		// Lücken werden mit leeren Intervallen gefüllt
+		"            xmin = 0\n            xmax = 1\n            text = \"\"\n",
+		"            xmin = 2.2\n            xmax = 3\n            text = \"Sag \"\"Hallo\"\"\"\n",
+		"            xmin = 3\n            xmax = 3.5\n            text = \"\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
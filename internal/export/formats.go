@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/store"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+func renderSRT(w io.Writer, t Transcript) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range t.cues() {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s: %s\n\n", i+1,
+			timestamp(c.start, ","), timestamp(c.end, ","), t.SpeakerName(c.Speaker), oneLine(c.Text))
+	}
+	return bw.Flush()
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func renderVTT(w io.Writer, t Transcript) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for i, c := range t.cues() {
+		// Voice spans let players show or style the speaker
+		fmt.Fprintf(bw, "%d\n%s --> %s\n<v %s>%s\n\n", i+1,
+			timestamp(c.start, "."), timestamp(c.end, "."),
+			vttEscaper.Replace(t.SpeakerName(c.Speaker)), vttEscaper.Replace(oneLine(c.Text)))
+	}
+	return bw.Flush()
+}
+
+func renderText(w io.Writer, t Transcript) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range t.cues() {
+		fmt.Fprintf(bw, "[%s] %s: %s\n", clock(c.start), t.SpeakerName(c.Speaker), oneLine(c.Text))
+	}
+	return bw.Flush()
+}
+
+// renderMarkdown writes one paragraph per turn: consecutive segments of the same
+// speaker are joined, which reads better in minutes.
+func renderMarkdown(w io.Writer, t Transcript) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Transkript")
+	if !t.Session.Created.IsZero() {
+		bw.WriteString(" " + t.Session.Created.Local().Format("02.01.2006 15:04"))
+	}
+	bw.WriteString("\n")
+
+	previous := ""
+	for i, c := range t.cues() {
+		name := t.SpeakerName(c.Speaker)
+		if i == 0 || name != previous {
+			fmt.Fprintf(bw, "\n**%s** (%s): ", name, clock(c.start))
+		} else {
+			bw.WriteString(" ")
+		}
+		bw.WriteString(oneLine(c.Text))
+		previous = name
+	}
+	if len(t.Segments) > 0 {
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// jsonSegment is a segment in the JSON export, with the speaker name resolved.
+type jsonSegment struct {
+	ID          string               `json:"id,omitempty"`
+	Speaker     string               `json:"speaker"`
+	SpeakerName string               `json:"speaker_name"`
+	Text        string               `json:"text"`
+	Start       float64              `json:"start"`
+	End         float64              `json:"end"`
+	Language    string               `json:"language,omitempty"`
+	Words       []transcription.Word `json:"words,omitempty"`
+	ReceivedAt  time.Time            `json:"received_at"`
+}
+
+type jsonTranscript struct {
+	Session  store.Session            `json:"session"`
+	Speakers map[string]store.Speaker `json:"speakers"`
+	Segments []jsonSegment            `json:"segments"`
+}
+
+func renderJSON(w io.Writer, t Transcript) error {
+	out := jsonTranscript{
+		Session:  t.Session,
+		Speakers: t.Speakers,
+		Segments: make([]jsonSegment, 0, len(t.Segments)),
+	}
+	if out.Speakers == nil {
+		out.Speakers = map[string]store.Speaker{}
+	}
+	for _, c := range t.cues() {
+		out.Segments = append(out.Segments, jsonSegment{
+			ID:          c.UtteranceID,
+			Speaker:     c.Speaker,
+			SpeakerName: t.SpeakerName(c.Speaker),
+			Text:        c.Text,
+			Start:       c.start,
+			End:         c.end,
+			Language:    c.Language,
+			Words:       c.Words,
+			ReceivedAt:  c.ReceivedAt,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// oneLine collapses line breaks and surrounding spaces, blank lines end subtitle cues.
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// renderTextGrid writes a Praat TextGrid in the long text format with one interval
+// tier per speaker and a word tier where word timings exist. Interval tiers cover
+// the whole session, gaps become empty intervals.
+func renderTextGrid(w io.Writer, t Transcript) error {
+	type gridTier struct {
+		name      string
+		intervals []interval
+	}
+
+	var grid []gridTier
+	end := 0.0
+	for _, tr := range t.tiers() {
+		utterances := gridTier{name: tr.name}
+		words := gridTier{name: wordTierName(tr.name)}
+		for _, s := range tr.spans {
+			utterances.intervals = append(utterances.intervals, s.interval)
+			words.intervals = append(words.intervals, s.words...)
+			end = max(end, s.end)
+		}
+		grid = append(grid, utterances)
+		if tr.hasWords() {
+			grid = append(grid, words)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "File type = \"ooTextFile\"\nObject class = \"TextGrid\"\n\nxmin = 0\nxmax = %s\n", seconds(end))
+	if len(grid) == 0 {
+		bw.WriteString("tiers? <absent>\n")
+		return bw.Flush()
+	}
+	fmt.Fprintf(bw, "tiers? <exists>\nsize = %d\nitem []:\n", len(grid))
+	for i, gt := range grid {
+		intervals := fillGaps(gt.intervals, end)
+		fmt.Fprintf(bw, "    item [%d]:\n", i+1)
+		fmt.Fprintf(bw, "        class = \"IntervalTier\"\n        name = %s\n", praatString(gt.name))
+		fmt.Fprintf(bw, "        xmin = 0\n        xmax = %s\n        intervals: size = %d\n", seconds(end), len(intervals))
+		for j, iv := range intervals {
+			fmt.Fprintf(bw, "        intervals [%d]:\n", j+1)
+			fmt.Fprintf(bw, "            xmin = %s\n            xmax = %s\n            text = %s\n",
+				seconds(iv.start), seconds(iv.end), praatString(iv.text))
+		}
+	}
+	return bw.Flush()
+}
+
+// fillGaps inserts empty intervals, so the sorted intervals cover [0, end] without gaps.
+func fillGaps(intervals []interval, end float64) []interval {
+	filled := make([]interval, 0, 2*len(intervals)+1)
+	previous := 0.0
+	for _, iv := range intervals {
+		if iv.start > previous {
+			filled = append(filled, interval{start: previous, end: iv.start})
+		}
+		filled = append(filled, iv)
+		previous = iv.end
+	}
+	if end > previous {
+		filled = append(filled, interval{start: previous, end: end})
+	}
+	return filled
+}
+
+func seconds(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// praatString quotes text for Praat, which escapes quotes by doubling them.
+func praatString(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
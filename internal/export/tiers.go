@@ -0,0 +1,114 @@
+package export
+
+import (
+	"math"
+	"sort"
+)
+
+// interval is an annotation on a tier, in seconds rounded to milliseconds.
+type interval struct {
+	start, end float64
+	text       string
+}
+
+// span is an utterance with its words.
+type span struct {
+	interval
+	words []interval
+}
+
+// tier holds the utterances of one speaker for ELAN and Praat.
+type tier struct {
+	name  string
+	spans []span
+}
+
+// hasWords reports whether any utterance of the tier has word timings.
+func (t tier) hasWords() bool {
+	for _, s := range t.spans {
+		if len(s.words) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// wordTierName is the name of the word tier below a speaker tier.
+func wordTierName(name string) string { return name + " (Wörter)" }
+
+// tiers groups the cues by speaker, in order of their first appearance. Both tools
+// expect the annotations of a tier not to overlap, so overlapping utterances are
+// shortened and words are clipped to their utterance. An utterance covered by the
+// previous one is kept with minSpan right after it.
+func (t Transcript) tiers() []tier {
+	var tiers []tier
+	index := make(map[string]int)
+	names := make(map[string]bool)
+	for _, c := range t.cues() {
+		i, ok := index[c.Speaker]
+		if !ok {
+			name := t.SpeakerName(c.Speaker)
+			if names[name] {
+				// Tier names must be unique, two speakers may share a name
+				name += " (" + c.Speaker + ")"
+			}
+			names[name] = true
+			i = len(tiers)
+			index[c.Speaker] = i
+			tiers = append(tiers, tier{name: name})
+		}
+
+		s := span{interval: interval{start: round(c.start), end: round(c.end), text: oneLine(c.Text)}}
+		for _, w := range c.Words {
+			if w.End > w.Start {
+				s.words = append(s.words, interval{start: round(w.Start), end: round(w.End), text: oneLine(w.Text)})
+			}
+		}
+		tiers[i].spans = append(tiers[i].spans, s)
+	}
+
+	for i := range tiers {
+		tiers[i].spans = clipSpans(tiers[i].spans)
+	}
+	return tiers
+}
+
+// minSpan is the length of an utterance that would vanish after clipping, one millisecond.
+const minSpan = 0.001
+
+func clipSpans(spans []span) []span {
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	previous := 0.0
+	for i := range spans {
+		s := &spans[i]
+		s.start = max(s.start, previous)
+		s.end = max(s.end, round(s.start+minSpan))
+		s.words = clipIntervals(s.words, s.start, s.end)
+		previous = s.end
+	}
+	return spans
+}
+
+// clipIntervals sorts the intervals and keeps them within [from, to] without overlaps.
+func clipIntervals(intervals []interval, from, to float64) []interval {
+	sort.SliceStable(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+
+	clipped := intervals[:0]
+	previous := from
+	for _, iv := range intervals {
+		iv.start = max(iv.start, previous)
+		iv.end = min(iv.end, to)
+		if iv.end <= iv.start {
+			continue
+		}
+		clipped = append(clipped, iv)
+		previous = iv.end
+	}
+	return clipped
+}
+
+// round rounds seconds to milliseconds, the resolution of ELAN.
+func round(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
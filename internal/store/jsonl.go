@@ -0,0 +1,193 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record types of a JSONL session file
+const (
+	recordSession = "session"
+	recordSpeaker = "speaker"
+	recordSegment = "segment"
+	recordEdit    = "edit"
+	recordClosed  = "closed"
+)
+
+// record is a line of a session file. Files are only appended to;
+// reading replays all records in order.
+type record struct {
+	Type      string     `json:"type"`
+	Session   *Session   `json:"session,omitempty"`
+	SpeakerID string     `json:"speaker_id,omitempty"`
+	Speaker   *Speaker   `json:"speaker,omitempty"`
+	Segment   *Segment   `json:"segment,omitempty"`
+	Edit      *Edit      `json:"edit,omitempty"`
+	Closed    *time.Time `json:"closed,omitempty"`
+}
+
+// sessionState is a session file after replaying its records.
+type sessionState struct {
+	session  Session
+	speakers map[string]Speaker
+	segments []Segment
+}
+
+// JSONL stores every session as "<id>.jsonl" in a directory.
+type JSONL struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenJSONL creates dir if needed.
+func OpenJSONL(dir string) (*JSONL, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &JSONL{dir: dir}, nil
+}
+
+func (s *JSONL) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".jsonl"), nil
+}
+
+// append writes a record. Only session records may create the file.
+func (s *JSONL) append(id string, rec record) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_APPEND
+	if rec.Type == recordSession {
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(path, flags, 0o640)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *JSONL) read(id string) (*sessionState, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	state := &sessionState{speakers: make(map[string]Speaker)}
+	dec := json.NewDecoder(f)
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			// End of file, or a truncated last line after a crash: keep what was read
+			break
+		}
+		state.apply(rec)
+	}
+	return state, nil
+}
+
+func (st *sessionState) apply(rec record) {
+	switch rec.Type {
+	case recordSession:
+		if rec.Session != nil {
+			st.session = *rec.Session
+		}
+	case recordSpeaker:
+		if rec.Speaker != nil {
+			st.speakers[rec.SpeakerID] = *rec.Speaker
+		}
+	case recordSegment:
+		if rec.Segment != nil {
+			st.segments = append(st.segments, *rec.Segment)
+		}
+	case recordEdit:
+		if rec.Edit != nil {
+			st.segments = applyEdit(st.segments, *rec.Edit)
+		}
+	case recordClosed:
+		if rec.Closed != nil {
+			st.session.Closed = *rec.Closed
+		}
+	}
+}
+
+func (s *JSONL) CreateSession(session Session) error {
+	return s.append(session.ID, record{Type: recordSession, Session: &session})
+}
+
+func (s *JSONL) CloseSession(id string, closed time.Time) error {
+	return s.append(id, record{Type: recordClosed, Closed: &closed})
+}
+
+func (s *JSONL) SaveSpeaker(sessionID, speakerID string, speaker Speaker) error {
+	return s.append(sessionID, record{Type: recordSpeaker, SpeakerID: speakerID, Speaker: &speaker})
+}
+
+func (s *JSONL) AppendSegment(sessionID string, segment Segment) error {
+	return s.append(sessionID, record{Type: recordSegment, Segment: &segment})
+}
+
+func (s *JSONL) AppendEdit(sessionID string, edit Edit) error {
+	return s.append(sessionID, record{Type: recordEdit, Edit: &edit})
+}
+
+func (s *JSONL) Session(id string) (Session, error) {
+	state, err := s.read(id)
+	if err != nil {
+		return Session{}, err
+	}
+	return state.session, nil
+}
+
+func (s *JSONL) Speakers(sessionID string) (map[string]Speaker, error) {
+	state, err := s.read(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return state.speakers, nil
+}
+
+func (s *JSONL) Segments(sessionID string) ([]Segment, error) {
+	state, err := s.read(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return state.segments, nil
+}
+
+// Close has nothing to release, files are opened per write.
+func (s *JSONL) Close() error { return nil }
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go driver, the Docker image is built with CGO_ENABLED=0
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id       TEXT PRIMARY KEY,
+	provider TEXT NOT NULL,
+	options  TEXT NOT NULL,
+	created  TEXT NOT NULL,
+	closed   TEXT
+);
+CREATE TABLE IF NOT EXISTS speakers (
+	session_id TEXT NOT NULL REFERENCES sessions(id),
+	speaker_id TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	PRIMARY KEY (session_id, speaker_id)
+);
+CREATE TABLE IF NOT EXISTS segments (
+	seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id   TEXT NOT NULL REFERENCES sessions(id),
+	utterance_id TEXT NOT NULL,
+	speaker      TEXT NOT NULL,
+	text         TEXT NOT NULL,
+	start_time   REAL NOT NULL,
+	end_time     REAL NOT NULL,
+	received_at  TEXT NOT NULL,
+	result       TEXT NOT NULL -- Full TranscriptResult as JSON
+);
+CREATE INDEX IF NOT EXISTS segments_session ON segments(session_id, seq);
+CREATE TABLE IF NOT EXISTS edits (
+	seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id   TEXT NOT NULL REFERENCES sessions(id),
+	utterance_id TEXT NOT NULL,
+	revision     INTEGER NOT NULL,
+	action       TEXT NOT NULL,
+	text         TEXT NOT NULL,
+	speaker      TEXT NOT NULL,
+	time         TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS edits_session ON edits(session_id, seq);
+`
+
+// SQLite stores sessions in an embedded database file.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates the database file and its tables.
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writes, SQLite allows only one writer anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating tables: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func formatTime(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) }
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
+
+func (s *SQLite) CreateSession(session Session) error {
+	options, err := json.Marshal(session.Options)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO sessions (id, provider, options, created) VALUES (?, ?, ?, ?)`,
+		session.ID, session.Provider, string(options), formatTime(session.Created))
+	return err
+}
+
+func (s *SQLite) CloseSession(id string, closed time.Time) error {
+	res, err := s.db.Exec(`UPDATE sessions SET closed = ? WHERE id = ?`, formatTime(closed), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (s *SQLite) SaveSpeaker(sessionID, speakerID string, speaker Speaker) error {
+	_, err := s.db.Exec(`INSERT INTO speakers (session_id, speaker_id, name, position) VALUES (?, ?, ?, ?)
+		ON CONFLICT (session_id, speaker_id) DO UPDATE SET name = excluded.name, position = excluded.position`,
+		sessionID, speakerID, speaker.Name, speaker.Position)
+	return mapConstraint(err)
+}
+
+func (s *SQLite) AppendSegment(sessionID string, segment Segment) error {
+	result, err := json.Marshal(segment.TranscriptResult)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO segments (session_id, utterance_id, speaker, text, start_time, end_time, received_at, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, segment.UtteranceID, segment.Speaker, segment.Text, segment.Start, segment.End,
+		formatTime(segment.ReceivedAt), string(result))
+	return mapConstraint(err)
+}
+
+// AppendEdit keeps the edit next to the original segment, Segments applies the edits on read.
+func (s *SQLite) AppendEdit(sessionID string, edit Edit) error {
+	_, err := s.db.Exec(`INSERT INTO edits (session_id, utterance_id, revision, action, text, speaker, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, edit.UtteranceID, edit.Revision, edit.Action, edit.Text, edit.Speaker, formatTime(edit.Time))
+	return mapConstraint(err)
+}
+
+func (s *SQLite) Session(id string) (Session, error) {
+	var (
+		session Session
+		options string
+		created string
+		closed  sql.NullString
+	)
+	err := s.db.QueryRow(`SELECT id, provider, options, created, closed FROM sessions WHERE id = ?`, id).
+		Scan(&session.ID, &session.Provider, &options, &created, &closed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	if err := json.Unmarshal([]byte(options), &session.Options); err != nil {
+		return Session{}, err
+	}
+	session.Created = parseTime(created)
+	if closed.Valid {
+		session.Closed = parseTime(closed.String)
+	}
+	return session, nil
+}
+
+func (s *SQLite) Speakers(sessionID string) (map[string]Speaker, error) {
+	if err := s.exists(sessionID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT speaker_id, name, position FROM speakers WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	speakers := make(map[string]Speaker)
+	for rows.Next() {
+		var id string
+		var speaker Speaker
+		if err := rows.Scan(&id, &speaker.Name, &speaker.Position); err != nil {
+			return nil, err
+		}
+		speakers[id] = speaker
+	}
+	return speakers, rows.Err()
+}
+
+func (s *SQLite) Segments(sessionID string) ([]Segment, error) {
+	if err := s.exists(sessionID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT result, received_at FROM segments WHERE session_id = ? ORDER BY seq`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []Segment
+	for rows.Next() {
+		var result, received string
+		if err := rows.Scan(&result, &received); err != nil {
+			return nil, err
+		}
+		var segment Segment
+		if err := json.Unmarshal([]byte(result), &segment.TranscriptResult); err != nil {
+			return nil, err
+		}
+		segment.ReceivedAt = parseTime(received)
+		segments = append(segments, segment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.applyEdits(sessionID, segments)
+}
+
+func (s *SQLite) applyEdits(sessionID string, segments []Segment) ([]Segment, error) {
+	rows, err := s.db.Query(`SELECT utterance_id, revision, action, text, speaker, time FROM edits
+		WHERE session_id = ? ORDER BY seq`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var edit Edit
+		var editTime string
+		if err := rows.Scan(&edit.UtteranceID, &edit.Revision, &edit.Action, &edit.Text, &edit.Speaker, &editTime); err != nil {
+			return nil, err
+		}
+		edit.Time = parseTime(editTime)
+		segments = applyEdit(segments, edit)
+	}
+	return segments, rows.Err()
+}
+
+func (s *SQLite) Close() error { return s.db.Close() }
+
+// exists returns ErrNotFound for unknown sessions.
+func (s *SQLite) exists(id string) error {
+	var found int
+	err := s.db.QueryRow(`SELECT 1 FROM sessions WHERE id = ?`, id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// mapConstraint reports writes for unknown sessions (foreign key violations) as ErrNotFound.
+func mapConstraint(err error) error {
+	if err != nil && strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+// Package store persists sessions, speakers and final transcript segments, so
+// transcripts survive the room. Backends are an append-only JSONL directory and
+// an embedded SQLite database.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Backend names for Open
+const (
+	BackendJSONL  = "jsonl"
+	BackendSQLite = "sqlite"
+)
+
+// ErrNotFound is returned for unknown sessions.
+var ErrNotFound = errors.New("session not found")
+
+// Session is the metadata of a stored session.
+type Session struct {
+	ID       string                `json:"id"`
+	Provider string                `json:"provider"`
+	Options  transcription.Options `json:"options"`
+	Created  time.Time             `json:"created"`
+	// Closed is zero while the room is running.
+	Closed time.Time `json:"closed,omitzero"`
+}
+
+// Speaker is the name and position of a speaker, set by the host.
+type Speaker struct {
+	Name     string `json:"name"`
+	Position int    `json:"position"` // 0 = Oben (Standard), 90 = Rechts, etc.
+}
+
+// Segment is a final transcript segment with the time the room received it.
+type Segment struct {
+	transcription.TranscriptResult
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Edit actions
+const (
+	EditText    = "text"
+	EditSpeaker = "speaker"
+	EditDelete  = "delete"
+)
+
+// Edit is a correction of a final segment by the host. Revision continues the
+// revision of the segment, every edit raises it by one.
+type Edit struct {
+	UtteranceID string    `json:"utterance_id"`
+	Revision    int       `json:"revision"`
+	Action      string    `json:"action"`
+	Text        string    `json:"text,omitempty"`
+	Speaker     string    `json:"speaker,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Validate checks that the edit is complete for its action.
+func (e Edit) Validate() error {
+	if e.UtteranceID == "" {
+		return errors.New("edit without utterance ID")
+	}
+	switch e.Action {
+	case EditText:
+		if strings.TrimSpace(e.Text) == "" {
+			return errors.New("empty text, delete the segment instead")
+		}
+	case EditSpeaker:
+		if e.Speaker == "" {
+			return errors.New("edit without speaker")
+		}
+	case EditDelete:
+	default:
+		return fmt.Errorf("unknown edit action %q", e.Action)
+	}
+	return nil
+}
+
+// Apply returns the edited segment, keep is false if the edit deletes it.
+func (e Edit) Apply(segment Segment) (edited Segment, keep bool) {
+	segment.Revision = e.Revision
+	switch e.Action {
+	case EditText:
+		segment.Text = e.Text
+		// Word timings belong to the recognized text, they do not match the correction
+		segment.Words = nil
+	case EditSpeaker:
+		segment.Speaker = e.Speaker
+		if len(segment.Words) > 0 {
+			// Copy, the words are shared with the unedited segment
+			words := make([]transcription.Word, len(segment.Words))
+			for i, word := range segment.Words {
+				if word.Speaker != "" {
+					word.Speaker = e.Speaker
+				}
+				words[i] = word
+			}
+			segment.Words = words
+		}
+	case EditDelete:
+		return segment, false
+	}
+	return segment, true
+}
+
+// applyEdit applies an edit to the segment with its utterance ID. Edits of
+// unknown segments are ignored.
+func applyEdit(segments []Segment, edit Edit) []Segment {
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i].UtteranceID != edit.UtteranceID {
+			continue
+		}
+		edited, keep := edit.Apply(segments[i])
+		if !keep {
+			return append(segments[:i], segments[i+1:]...)
+		}
+		segments[i] = edited
+		break
+	}
+	return segments
+}
+
+// Store is implemented by the backends. Segments are returned in the order they were
+// appended, with the edits applied; deleted segments are left out.
+type Store interface {
+	CreateSession(session Session) error
+	CloseSession(id string, closed time.Time) error
+	SaveSpeaker(sessionID, speakerID string, speaker Speaker) error
+	AppendSegment(sessionID string, segment Segment) error
+	AppendEdit(sessionID string, edit Edit) error
+
+	Session(id string) (Session, error)
+	Speakers(sessionID string) (map[string]Speaker, error)
+	Segments(sessionID string) ([]Segment, error)
+
+	Close() error
+}
+
+// Open opens the backend at path: a directory for "jsonl", a database file for "sqlite".
+func Open(backend, path string) (Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("store %s needs a path", backend)
+	}
+	switch backend {
+	case BackendJSONL:
+		return OpenJSONL(path)
+	case BackendSQLite:
+		return OpenSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q (jsonl or sqlite)", backend)
+	}
+}
@@ -0,0 +1,188 @@
+package store_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/store"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Compile-time checks
+var _ store.Store = (*store.JSONL)(nil)
+var _ store.Store = (*store.SQLite)(nil)
+
+func TestBackends(t *testing.T) {
+	for _, backend := range []string{store.BackendJSONL, store.BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			s, err := store.Open(backend, path)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			testStore(t, s)
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			// Daten überleben einen Neustart
+			reopened, err := store.Open(backend, path)
+			if err != nil {
+				t.Fatalf("Reopen failed: %v", err)
+			}
+			defer reopened.Close()
+			if segments, err := reopened.Segments("room1"); err != nil || len(segments) != 2 {
+				t.Errorf("Expected 2 segments after reopening, got %d (%v)", len(segments), err)
+			}
+		})
+	}
+}
+
+func testStore(t *testing.T, s store.Store) {
+	created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	err := s.CreateSession(store.Session{
+		ID:       "room1",
+		Provider: "mock",
+		Options:  transcription.Options{Language: "de-CH", Vocabulary: []string{"Tolka"}},
+		Created:  created,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := s.CreateSession(store.Session{ID: "room1", Created: created}); err == nil {
+		t.Error("Expected error for duplicate session")
+	}
+
+	s.SaveSpeaker("room1", "Speaker 1", store.Speaker{Name: "Anna", Position: 90})
+	s.SaveSpeaker("room1", "Speaker 1", store.Speaker{Name: "Anna", Position: 180})
+	s.SaveSpeaker("room1", "Speaker 2", store.Speaker{Name: "Fritz"})
+
+	for i, text := range []string{"Guten Morgen", "Wie geht's?"} {
+		err := s.AppendSegment("room1", store.Segment{
+			TranscriptResult: transcription.TranscriptResult{
+				Text:        text,
+				Speaker:     "Speaker 1",
+				Start:       float64(i),
+				End:         float64(i) + 0.8,
+				UtteranceID: "u" + string(rune('1'+i)),
+				Words:       []transcription.Word{{Text: text, Start: float64(i), End: float64(i) + 0.8}},
+			},
+			ReceivedAt: created.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("AppendSegment failed: %v", err)
+		}
+	}
+
+	// Korrekturen des Hosts
+	s.AppendSegment("room1", store.Segment{TranscriptResult: transcription.TranscriptResult{Text: "Äh", UtteranceID: "u3"}})
+	edits := []store.Edit{
+		{UtteranceID: "u1", Revision: 2, Action: store.EditText, Text: "Guten Morgen, Anna"},
+		{UtteranceID: "u2", Revision: 2, Action: store.EditSpeaker, Speaker: "Speaker 2"},
+		{UtteranceID: "u3", Revision: 2, Action: store.EditDelete},
+		{UtteranceID: "u9", Revision: 1, Action: store.EditDelete},
+	}
+	for _, edit := range edits {
+		if err := s.AppendEdit("room1", edit); err != nil {
+			t.Fatalf("AppendEdit failed: %v", err)
+		}
+	}
+
+	if err := s.CloseSession("room1", created.Add(time.Hour)); err != nil {
+		t.Fatalf("CloseSession failed: %v", err)
+	}
+
+	session, err := s.Session("room1")
+	if err != nil {
+		t.Fatalf("Session failed: %v", err)
+	}
+	if session.Provider != "mock" || session.Options.Vocabulary[0] != "Tolka" || !session.Created.Equal(created) || !session.Closed.Equal(created.Add(time.Hour)) {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+
+	speakers, err := s.Speakers("room1")
+	if err != nil {
+		t.Fatalf("Speakers failed: %v", err)
+	}
+	if len(speakers) != 2 || speakers["Speaker 1"].Position != 180 || speakers["Speaker 2"].Name != "Fritz" {
+		t.Errorf("Unexpected speakers: %v", speakers)
+	}
+
+	segments, err := s.Segments("room1")
+	if err != nil {
+		t.Fatalf("Segments failed: %v", err)
+	}
+	if len(segments) != 2 || segments[0].Text != "Guten Morgen, Anna" || segments[1].UtteranceID != "u2" {
+		t.Fatalf("Unexpected segments: %+v", segments)
+	}
+	if len(segments[1].Words) != 1 || segments[1].End != 1.8 || !segments[1].ReceivedAt.Equal(created.Add(time.Second)) {
+		t.Errorf("Expected timings to be kept, got %+v", segments[1])
+	}
+	if segments[0].Revision != 2 || segments[0].Words != nil || segments[1].Speaker != "Speaker 2" {
+		t.Errorf("Expected edits to be applied, got %+v", segments)
+	}
+
+	// Unbekannte Sessions
+	if _, err := s.Session("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Segments("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for segments, got %v", err)
+	}
+	if err := s.AppendSegment("missing", store.Segment{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for append, got %v", err)
+	}
+	if err := s.AppendEdit("missing", edits[0]); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for edit, got %v", err)
+	}
+}
+
+func TestJSONL_TruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.OpenJSONL(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	s.CreateSession(store.Session{ID: "room1", Provider: "mock"})
+	s.AppendSegment("room1", store.Segment{TranscriptResult: transcription.TranscriptResult{Text: "Hallo"}})
+
+	// Ein Absturz mitten im Schreiben hinterlässt eine halbe Zeile
+	f, _ := os.OpenFile(filepath.Join(dir, "room1.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"type":"segment","segment":{"te`)
+	f.Close()
+
+	if segments, err := s.Segments("room1"); err != nil || len(segments) != 1 {
+		t.Errorf("Expected the complete segment, got %d (%v)", len(segments), err)
+	}
+	if _, err := s.Session("../room1"); err == nil {
+		t.Error("Expected error for invalid session ID")
+	}
+}
+
+func TestEdit_Validate(t *testing.T) {
+	for _, edit := range []store.Edit{
+		{Action: store.EditDelete},
+		{UtteranceID: "u1", Action: store.EditText, Text: "  "},
+		{UtteranceID: "u1", Action: store.EditSpeaker},
+		{UtteranceID: "u1", Action: "merge"},
+	} {
+		if err := edit.Validate(); err == nil {
+			t.Errorf("Expected error for %+v", edit)
+		}
+	}
+	if err := (store.Edit{UtteranceID: "u1", Action: store.EditText, Text: "Hallo"}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	if _, err := store.Open("postgres", t.TempDir()); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+	if _, err := store.Open(store.BackendJSONL, ""); err == nil {
+		t.Error("Expected error without path")
+	}
+}
@@ -0,0 +1,32 @@
+package transcription
+
+// Speech activity event types
+const (
+	SpeechStart = "speech_start"
+	SpeechEnd   = "speech_end"
+)
+
+// SpeechEvent tells viewers that someone started or stopped talking, usually
+// before the words of the utterance arrive.
+type SpeechEvent struct {
+	Type string `json:"type"`
+	// Speaker is empty if the provider does not know it yet.
+	Speaker string `json:"speaker,omitempty"`
+	// Time is the position in the audio stream in seconds, zero if unknown.
+	Time float64 `json:"time,omitempty"`
+}
+
+// ActivityReporter is implemented by services that detect speech activity.
+// The channel is never closed.
+type ActivityReporter interface {
+	ActivityChan() <-chan SpeechEvent
+}
+
+// ActivityChan returns the activity channel of the service, nil if it reports none.
+// Receiving from a nil channel blocks forever, so select cases stay inactive.
+func ActivityChan(service Service) <-chan SpeechEvent {
+	if reporter, ok := service.(ActivityReporter); ok {
+		return reporter.ActivityChan()
+	}
+	return nil
+}
@@ -0,0 +1,477 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// NativeConfig describes the Speech service connection of the native client.
+type NativeConfig struct {
+	Key    string
+	Region string
+	// Endpoint overrides the regional URL, e.g. for sovereign clouds or tests.
+	Endpoint string
+}
+
+// endpoint returns the conversation transcription URL of the region.
+func (c NativeConfig) endpoint() string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+	return fmt.Sprintf("wss://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1", c.Region)
+}
+
+// writeWait bounds every write to the service. A half-open connection must not
+// block SendAudio, and with it Close, forever.
+const writeWait = 5 * time.Second
+
+// NativeProvider talks to the Speech service's WebSocket protocol directly,
+// the same way the Speech SDK's ConversationTranscriber does. Unlike Provider
+// it needs neither Python nor the SDK.
+//
+// The phrase list is part of the session context. Vocabulary changes therefore
+// apply from the next connection on.
+type NativeProvider struct {
+	cfg NativeConfig
+
+	logger     *slog.Logger
+	conn       *websocket.Conn
+	requestID  string
+	readerDone chan struct{}
+	resChan    chan transcription.TranscriptResult
+	errChan    chan error
+	activity   chan transcription.SpeechEvent
+	// lastSpeaker is reported with speech.endDetected, only used by readLoop
+	lastSpeaker string
+
+	mu       sync.Mutex
+	isClosed bool
+}
+
+func NewNative(cfg NativeConfig) *NativeProvider {
+	return &NativeProvider{
+		cfg:      cfg,
+		resChan:  make(chan transcription.TranscriptResult, 100),
+		errChan:  make(chan error, 10),
+		activity: make(chan transcription.SpeechEvent, 10),
+	}
+}
+
+func (p *NativeProvider) Connect(ctx context.Context, opts transcription.Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed {
+		return errors.New("provider is closed")
+	}
+	p.logger = transcription.LoggerFrom(ctx).With("provider", "Azure")
+
+	u, err := url.Parse(p.cfg.endpoint())
+	if err != nil {
+		return fmt.Errorf("azure: invalid endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("language", opts.Language)
+	q.Set("format", "detailed")
+	u.RawQuery = q.Encode()
+
+	header := http.Header{}
+	header.Set("Ocp-Apim-Subscription-Key", p.cfg.Key)
+	header.Set("X-ConnectionId", newRequestID())
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		return handshakeError(resp, err)
+	}
+	p.conn = conn
+	p.requestID = newRequestID()
+
+	if err := p.sendSetup(opts); err != nil {
+		conn.Close()
+		return &transcription.ProviderError{Provider: "Azure", Kind: transcription.ErrNetwork, Detail: err.Error()}
+	}
+
+	p.readerDone = make(chan struct{})
+	go p.readLoop()
+
+	p.logger.Info("Connected to Speech service", "endpoint", u.Host, "language", opts.Language, "auto_detect", opts.AutoDetect)
+	return nil
+}
+
+// handshakeError classifies a failed WebSocket upgrade by its HTTP status.
+func handshakeError(resp *http.Response, err error) error {
+	if resp == nil {
+		return &transcription.ProviderError{Provider: "Azure", Kind: transcription.ErrNetwork, Detail: err.Error()}
+	}
+
+	kind := transcription.ErrService
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = transcription.ErrAuth
+	case http.StatusTooManyRequests:
+		kind = transcription.ErrQuota
+	}
+	return &transcription.ProviderError{
+		Provider: "Azure",
+		Kind:     kind,
+		Detail:   fmt.Sprintf("handshake failed with status %d", resp.StatusCode),
+	}
+}
+
+// speechConfig describes the client and the audio source.
+type speechConfig struct {
+	Context struct {
+		System map[string]string `json:"system"`
+		OS     map[string]string `json:"os"`
+		Audio  struct {
+			Source map[string]interface{} `json:"source"`
+		} `json:"audio"`
+	} `json:"context"`
+	Recognition string `json:"recognition"`
+}
+
+// speechContext enables conversation transcription for the turn.
+type speechContext struct {
+	PhraseDetection struct {
+		Mode string `json:"mode"`
+	} `json:"phraseDetection"`
+	PhraseOutput struct {
+		Format   string `json:"format"`
+		Detailed struct {
+			Options []string `json:"options"`
+		} `json:"detailed"`
+	} `json:"phraseOutput"`
+	Diarization struct {
+		Mode                 string `json:"mode"`
+		IdentityProvider     string `json:"identityProvider"`
+		AudioSessionID       string `json:"audioSessionId"`
+		DiarizeIntermediates bool   `json:"diarizeIntermediates"`
+	} `json:"diarization"`
+	DGI        *phraseGroups `json:"dgi,omitempty"`
+	LanguageID *languageID   `json:"languageId,omitempty"`
+}
+
+// phraseGroups is the phrase list (PhraseListGrammar in the SDK).
+type phraseGroups struct {
+	Groups []phraseGroup `json:"Groups"`
+}
+
+type phraseGroup struct {
+	Type  string              `json:"Type"`
+	Items []map[string]string `json:"Items"`
+}
+
+type languageID struct {
+	Languages []string          `json:"languages"`
+	OnSuccess map[string]string `json:"onSuccess"`
+	OnUnknown map[string]string `json:"onUnknown"`
+	Mode      string            `json:"mode"`
+	Priority  string            `json:"priority"`
+}
+
+// sendSetup sends config, context and the WAV header. Callers must hold p.mu.
+func (p *NativeProvider) sendSetup(opts transcription.Options) error {
+	var cfg speechConfig
+	cfg.Context.System = map[string]string{"name": "Tolka", "build": "Go", "lang": "Go"}
+	cfg.Context.OS = map[string]string{"platform": runtime.GOOS, "name": "Go", "version": runtime.Version()}
+	cfg.Context.Audio.Source = map[string]interface{}{
+		"type": "Stream", "model": "PushStream",
+		"samplerate": 16000, "bitspersample": 16, "channelcount": 1,
+	}
+	cfg.Recognition = "conversation"
+
+	var sc speechContext
+	sc.PhraseDetection.Mode = "Conversation"
+	sc.PhraseOutput.Format = "Detailed"
+	sc.PhraseOutput.Detailed.Options = []string{"WordTimings"}
+	sc.Diarization.Mode = "Anonymous"
+	sc.Diarization.IdentityProvider = "CallCenter"
+	sc.Diarization.AudioSessionID = p.requestID
+	sc.Diarization.DiarizeIntermediates = true
+
+	if len(opts.Vocabulary) > 0 {
+		group := phraseGroup{Type: "Generic"}
+		for _, phrase := range opts.Vocabulary {
+			group.Items = append(group.Items, map[string]string{"Text": phrase})
+		}
+		sc.DGI = &phraseGroups{Groups: []phraseGroup{group}}
+	}
+	if opts.AutoDetect {
+		// Kontinuierliche Spracherkennung wie im Python Worker
+		sc.LanguageID = &languageID{
+			Languages: opts.Candidates(),
+			OnSuccess: map[string]string{"action": "Recognize"},
+			OnUnknown: map[string]string{"action": "None"},
+			Mode:      "DetectContinuous",
+			Priority:  "PrioritizeLatency",
+		}
+	}
+
+	if err := p.writeJSON(pathConfig, cfg); err != nil {
+		return err
+	}
+	if err := p.writeJSON(pathContext, sc); err != nil {
+		return err
+	}
+	return p.write(websocket.BinaryMessage, encodeAudio(p.requestID, wavHeader()))
+}
+
+// write sends a message with a deadline. Callers must hold p.mu.
+func (p *NativeProvider) write(messageType int, data []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return p.conn.WriteMessage(messageType, data)
+}
+
+// writeJSON sends a text message. Callers must hold p.mu.
+func (p *NativeProvider) writeJSON(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return p.write(websocket.TextMessage, encodeText(path, p.requestID, data))
+}
+
+// recognitionResult is the body of speech.hypothesis and speech.phrase messages.
+// Offsets and durations are ticks (100 ns).
+type recognitionResult struct {
+	RecognitionStatus string
+	Text              string // Hypothesis
+	DisplayText       string // Phrase
+	Offset            int64
+	Duration          int64
+	SpeakerID         string `json:"SpeakerId"`
+	PrimaryLanguage   *struct {
+		Language string
+	}
+	NBest []struct {
+		Display string
+		Words   []struct {
+			Word       string
+			Offset     int64
+			Duration   int64
+			Confidence float64
+		}
+	}
+}
+
+const ticksPerSecond = 10_000_000
+
+func ticksToSeconds(ticks int64) float64 {
+	return float64(ticks) / ticksPerSecond
+}
+
+// convert maps a service result onto a TranscriptResult.
+func (r recognitionResult) convert(partial bool) transcription.TranscriptResult {
+	speaker := r.SpeakerID
+	if speaker == "" {
+		speaker = "Unknown"
+	}
+
+	result := transcription.TranscriptResult{
+		Text:      strings.TrimSpace(r.Text),
+		Speaker:   speaker,
+		IsPartial: partial,
+		Start:     ticksToSeconds(r.Offset),
+		End:       ticksToSeconds(r.Offset + r.Duration),
+	}
+	if r.PrimaryLanguage != nil {
+		result.Language = r.PrimaryLanguage.Language
+	}
+	if partial {
+		return result
+	}
+
+	result.Text = strings.TrimSpace(r.DisplayText)
+	if len(r.NBest) > 0 {
+		if result.Text == "" {
+			result.Text = strings.TrimSpace(r.NBest[0].Display)
+		}
+		// Azure liefert den Speaker pro Phrase, alle Wörter erben ihn
+		for _, w := range r.NBest[0].Words {
+			result.Words = append(result.Words, transcription.Word{
+				Text:       w.Word,
+				Start:      ticksToSeconds(w.Offset),
+				End:        ticksToSeconds(w.Offset + w.Duration),
+				Confidence: w.Confidence,
+				Speaker:    speaker,
+			})
+		}
+	}
+	return result
+}
+
+func (p *NativeProvider) readLoop() {
+	defer close(p.readerDone)
+
+	for {
+		msgType, data, err := p.conn.ReadMessage()
+		if err != nil {
+			if !p.closed() {
+				p.report(closeError(err))
+			}
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+
+		msg, err := decodeText(data)
+		if err != nil {
+			p.logger.Warn("Failed to parse message", "error", err)
+			continue
+		}
+
+		switch msg.path {
+		case pathHypothesis, pathPhrase:
+			var rr recognitionResult
+			if err := json.Unmarshal(msg.body, &rr); err != nil {
+				p.logger.Warn("Failed to parse result", "path", msg.path, "error", err)
+				continue
+			}
+			if msg.path == pathPhrase && rr.RecognitionStatus != "Success" {
+				p.handleStatus(rr.RecognitionStatus)
+				continue
+			}
+			p.emit(rr.convert(msg.path == pathHypothesis))
+
+		case pathTurnEnd:
+			if p.closed() {
+				return
+			}
+			// Der Dienst beendet Turns nach Zeitlimit; der Reconnect-Decorator baut neu auf
+			p.report(&transcription.ProviderError{Provider: "Azure", Kind: transcription.ErrNetwork, Detail: "turn ended by service"})
+			return
+
+		case pathSpeechStart, pathSpeechEnd:
+			var marker struct{ Offset int64 }
+			json.Unmarshal(msg.body, &marker)
+			event := transcription.SpeechEvent{Type: transcription.SpeechStart, Time: ticksToSeconds(marker.Offset)}
+			if msg.path == pathSpeechEnd {
+				event.Type, event.Speaker = transcription.SpeechEnd, p.lastSpeaker
+			}
+			select {
+			case p.activity <- event:
+			default:
+			}
+
+		case pathTurnStart:
+			p.logger.Debug("Service event", "path", msg.path)
+		}
+	}
+}
+
+// statusKinds maps failed recognition statuses onto error kinds.
+// NoMatch and the timeouts are normal during silence and not listed.
+var statusKinds = map[string]error{
+	"Error":           transcription.ErrService,
+	"BadRequest":      transcription.ErrService,
+	"Forbidden":       transcription.ErrAuth,
+	"TooManyRequests": transcription.ErrQuota,
+}
+
+func (p *NativeProvider) handleStatus(status string) {
+	kind, failed := statusKinds[status]
+	if !failed {
+		p.logger.Debug("Phrase without result", "status", status)
+		return
+	}
+	p.report(&transcription.ProviderError{Provider: "Azure", Kind: kind, Detail: "recognition status " + status})
+}
+
+// closeError classifies a failed read. Close frames carry the service's reason.
+func closeError(err error) error {
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return &transcription.ProviderError{
+			Provider: "Azure",
+			Kind:     transcription.ErrService,
+			Detail:   fmt.Sprintf("connection closed (%d): %s", ce.Code, ce.Text),
+		}
+	}
+	return &transcription.ProviderError{Provider: "Azure", Kind: transcription.ErrNetwork, Detail: err.Error()}
+}
+
+func (p *NativeProvider) emit(result transcription.TranscriptResult) {
+	if result.Text == "" {
+		return
+	}
+	p.lastSpeaker = result.Speaker
+	select {
+	case p.resChan <- result:
+	default:
+		p.logger.Warn("Result channel full")
+	}
+}
+
+func (p *NativeProvider) report(err error) {
+	select {
+	case p.errChan <- err:
+	default:
+	}
+}
+
+func (p *NativeProvider) closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isClosed
+}
+
+func (p *NativeProvider) SendAudio(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed || p.conn == nil {
+		return errors.New("azure not connected")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return p.write(websocket.BinaryMessage, encodeAudio(p.requestID, data))
+}
+
+func (p *NativeProvider) Close() error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.isClosed = true
+	conn := p.conn
+	p.mu.Unlock()
+
+	// Nach isClosed schreibt niemand mehr auf conn
+	if conn != nil {
+		// Leere Audio-Nachricht markiert das Ende des Streams
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		conn.WriteMessage(websocket.BinaryMessage, encodeAudio(p.requestID, nil))
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+		conn.Close()
+	}
+
+	if p.readerDone != nil {
+		<-p.readerDone
+	}
+
+	close(p.resChan)
+	close(p.errChan)
+	if p.logger != nil {
+		p.logger.Info("Connection closed")
+	}
+	return nil
+}
+
+func (p *NativeProvider) ResultChan() <-chan transcription.TranscriptResult { return p.resChan }
+func (p *NativeProvider) ErrorChan() <-chan error                           { return p.errChan }
+func (p *NativeProvider) ActivityChan() <-chan transcription.SpeechEvent    { return p.activity }
@@ -0,0 +1,218 @@
+package azure
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Compile-time check
+var _ transcription.Service = (*NativeProvider)(nil)
+
+// fakeSpeechService simuliert den Speech-Dienst: Jedes Audio-Paket wird als Hypothese und Phrase zurückgeschickt.
+type fakeSpeechService struct {
+	mu       sync.Mutex
+	language string
+	paths    []string
+	context  speechContext
+}
+
+func (f *fakeSpeechService) handler(t *testing.T) http.HandlerFunc {
+	upgrader := websocket.Upgrader{}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Ocp-Apim-Subscription-Key") != "secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		f.mu.Lock()
+		f.language = r.URL.Query().Get("language")
+		f.mu.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		send := func(path, body string) {
+			msg := fmt.Sprintf("Path: %s\r\nX-RequestId: 1\r\nContent-Type: application/json\r\n\r\n%s", path, body)
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+
+		offset := int64(0)
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var path string
+			var audio []byte
+			if msgType == websocket.TextMessage {
+				msg, err := decodeText(data)
+				if err != nil {
+					t.Errorf("Invalid text message: %v", err)
+					return
+				}
+				path = msg.path
+				if path == pathContext {
+					f.mu.Lock()
+					json.Unmarshal(msg.body, &f.context)
+					f.mu.Unlock()
+				}
+			} else {
+				path, audio, err = decodeAudio(data)
+				if err != nil {
+					t.Errorf("Invalid binary message: %v", err)
+					return
+				}
+			}
+
+			f.mu.Lock()
+			f.paths = append(f.paths, path)
+			f.mu.Unlock()
+
+			// WAV-Header und End-of-Stream werden nicht transkribiert
+			if path != pathAudio || len(audio) == 0 || strings.HasPrefix(string(audio), "RIFF") {
+				continue
+			}
+			text := string(audio)
+			send(pathSpeechStart, fmt.Sprintf(`{"Offset":%d}`, offset))
+			send(pathHypothesis, fmt.Sprintf(`{"Text":%q,"Offset":%d,"Duration":5000000,"SpeakerId":"Guest-1"}`, text, offset))
+			send(pathSpeechEnd, fmt.Sprintf(`{"Offset":%d}`, offset+5000000))
+			send(pathPhrase, fmt.Sprintf(`{"RecognitionStatus":"Success","DisplayText":%q,"Offset":%d,"Duration":5000000,"SpeakerId":"Guest-1",
+				"PrimaryLanguage":{"Language":"de-CH"},
+				"NBest":[{"Display":%q,"Words":[{"Word":%q,"Offset":%d,"Duration":5000000,"Confidence":0.9}]}]}`,
+				text+".", offset, text+".", text, offset))
+			offset += 5000000
+		}
+	}
+}
+
+// decodeAudio splits a binary audio message into path and audio.
+func decodeAudio(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, errors.New("binary message too short")
+	}
+	size := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+size {
+		return "", nil, errors.New("binary header exceeds message")
+	}
+	msg, err := decodeText(append(data[2:2+size:2+size], "\r\n"...))
+	if err != nil {
+		return "", nil, err
+	}
+	return msg.path, data[2+size:], nil
+}
+
+func TestNativeProvider_RoundTrip(t *testing.T) {
+	fake := &fakeSpeechService{}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	provider := NewNative(NativeConfig{
+		Key:      "secret",
+		Endpoint: "ws" + strings.TrimPrefix(server.URL, "http"),
+	})
+	err := provider.Connect(context.Background(), transcription.Options{
+		Language:   "de-CH",
+		Languages:  []string{"de-CH", "en-US"},
+		AutoDetect: true,
+		Vocabulary: []string{"Tolka"},
+	})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	if err := provider.SendAudio([]byte("Hallo")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+
+	var results []transcription.TranscriptResult
+	for len(results) < 2 {
+		select {
+		case res := <-provider.ResultChan():
+			results = append(results, res)
+		case err := <-provider.ErrorChan():
+			t.Fatalf("Unexpected error: %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for results")
+		}
+	}
+
+	expected := []transcription.SpeechEvent{
+		{Type: transcription.SpeechStart},
+		{Type: transcription.SpeechEnd, Speaker: "Guest-1", Time: 0.5},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-provider.ActivityChan():
+			if got != want {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for %s", want.Type)
+		}
+	}
+	provider.Close()
+
+	partial, final := results[0], results[1]
+	if !partial.IsPartial || partial.Text != "Hallo" || partial.Speaker != "Guest-1" {
+		t.Errorf("Unexpected partial: %+v", partial)
+	}
+	if final.IsPartial || final.Text != "Hallo." || final.Language != "de-CH" || final.End != 0.5 {
+		t.Errorf("Unexpected final: %+v", final)
+	}
+	if len(final.Words) != 1 || final.Words[0].Speaker != "Guest-1" || final.Words[0].Confidence != 0.9 {
+		t.Errorf("Unexpected words: %+v", final.Words)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.language != "de-CH" {
+		t.Errorf("Expected language de-CH, got %q", fake.language)
+	}
+	if len(fake.paths) < 3 || fake.paths[0] != pathConfig || fake.paths[1] != pathContext || fake.paths[2] != pathAudio {
+		t.Errorf("Expected config, context and audio first, got %v", fake.paths)
+	}
+	if fake.context.PhraseDetection.Mode != "Conversation" || fake.context.Diarization.Mode != "Anonymous" {
+		t.Errorf("Conversation transcription not requested: %+v", fake.context)
+	}
+	if fake.context.DGI == nil || fake.context.DGI.Groups[0].Items[0]["Text"] != "Tolka" {
+		t.Errorf("Expected phrase list with Tolka, got %+v", fake.context.DGI)
+	}
+	if fake.context.LanguageID == nil || len(fake.context.LanguageID.Languages) != 2 {
+		t.Errorf("Expected language identification, got %+v", fake.context.LanguageID)
+	}
+}
+
+func TestNativeProvider_AuthFailure(t *testing.T) {
+	fake := &fakeSpeechService{}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	provider := NewNative(NativeConfig{
+		Key:      "wrong",
+		Endpoint: "ws" + strings.TrimPrefix(server.URL, "http"),
+	})
+	defer provider.Close()
+
+	err := provider.Connect(context.Background(), transcription.Options{Language: "de-CH"})
+	if !errors.Is(err, transcription.ErrAuth) {
+		t.Errorf("Expected ErrAuth, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The Speech service frames every WebSocket message with HTTP-like headers.
+// Text messages: header lines, an empty line and the JSON body.
+// Binary messages: a two byte big-endian header length, the headers and the audio.
+const (
+	pathConfig  = "speech.config"
+	pathContext = "speech.context"
+	pathAudio   = "audio"
+
+	pathTurnStart   = "turn.start"
+	pathTurnEnd     = "turn.end"
+	pathSpeechStart = "speech.startDetected"
+	pathSpeechEnd   = "speech.endDetected"
+	pathHypothesis  = "speech.hypothesis"
+	pathPhrase      = "speech.phrase"
+)
+
+// message is a decoded text message from the service.
+type message struct {
+	path      string
+	requestID string
+	body      []byte
+}
+
+// newRequestID returns a random id in the format the service expects (32 hex digits, no dashes).
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return strings.ToUpper(hex.EncodeToString(b[:]))
+}
+
+func headerBlock(path, requestID, contentType string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Path: %s\r\n", path)
+	fmt.Fprintf(&sb, "X-RequestId: %s\r\n", requestID)
+	fmt.Fprintf(&sb, "X-Timestamp: %s\r\n", time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	if contentType != "" {
+		fmt.Fprintf(&sb, "Content-Type: %s\r\n", contentType)
+	}
+	return sb.String()
+}
+
+func encodeText(path, requestID string, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(headerBlock(path, requestID, "application/json; charset=utf-8"))
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func encodeAudio(requestID string, audio []byte) []byte {
+	header := headerBlock(pathAudio, requestID, "audio/x-wav")
+	buf := make([]byte, 2, 2+len(header)+len(audio))
+	binary.BigEndian.PutUint16(buf, uint16(len(header)))
+	buf = append(buf, header...)
+	return append(buf, audio...)
+}
+
+// decodeText splits a text message into headers and body.
+func decodeText(data []byte) (message, error) {
+	head, body, ok := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !ok {
+		return message{}, errors.New("message without header block")
+	}
+
+	var msg message
+	for _, line := range strings.Split(string(head), "\r\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			msg.path = strings.TrimSpace(value)
+		case "x-requestid":
+			msg.requestID = strings.TrimSpace(value)
+		}
+	}
+	if msg.path == "" {
+		return message{}, errors.New("message without path")
+	}
+	msg.body = body
+	return msg, nil
+}
+
+// wavHeader describes the raw PCM stream (16 kHz, 16 bit, mono). The service
+// expects it in front of the first audio chunk; the sizes are left open.
+func wavHeader() []byte {
+	const (
+		sampleRate    = 16000
+		bitsPerSample = 16
+		channels      = 1
+	)
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	return buf.Bytes()
+}
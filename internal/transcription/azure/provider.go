@@ -1,39 +1,55 @@
 package azure
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sync"
+	"time"
 
 	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/stdio"
 )
 
-type Provider struct {
-	subscriptionKey string
-	region          string
+// Languages lists the locales supported by the ConversationTranscriber.
+var Languages = []string{
+	"de-CH", "de-DE", "de-AT",
+	"en-US", "en-GB",
+	"fr-CH", "fr-FR",
+	"it-CH", "it-IT",
+	"es-ES",
+}
+
+// Capabilities describes the features of the Azure provider.
+var Capabilities = transcription.Capabilities{
+	Diarization: true,
+	Partials:    true,
+	WordTimings: true,
+	AutoDetect:  true,
+	Languages:   Languages,
+	AudioFormat: transcription.AudioPCM16,
+}
 
-	cmd     *exec.Cmd
-	stdin   io.WriteCloser
-	resChan chan transcription.TranscriptResult
-	errChan chan error
+// maxAutoDetectLanguages is the candidate limit of Azure's continuous language identification.
+const maxAutoDetectLanguages = 10
 
-	mu       sync.Mutex
-	isClosed bool
+// ValidateOptions checks the session options against the locales Azure supports.
+func ValidateOptions(opts transcription.Options) error {
+	if err := opts.Validate(Languages); err != nil {
+		return err
+	}
+	if opts.AutoDetect && len(opts.Candidates()) > maxAutoDetectLanguages {
+		return fmt.Errorf("auto detection supports at most %d languages", maxAutoDetectLanguages)
+	}
+	return nil
 }
 
-// JSON Struktur vom Python Worker
-type pythonResult struct {
-	Text      string `json:"text"`
-	IsPartial bool   `json:"is_partial"`
-	Speaker   string `json:"speaker"`
+// Provider runs azure_worker.py as stdio worker. The Python Speech SDK is used
+// because the Go SDK does not support the ConversationTranscriber (diarization).
+type Provider struct {
+	*stdio.Provider
+	workerErr error
 }
 
 func getWorkerPath() (string, error) {
@@ -60,124 +76,27 @@ func getWorkerPath() (string, error) {
 }
 
 func New(key, region string) *Provider {
-	return &Provider{
-		subscriptionKey: key,
-		region:          region,
-		resChan:         make(chan transcription.TranscriptResult, 100),
-		errChan:         make(chan error, 10),
-	}
-}
-
-func (p *Provider) Connect(ctx context.Context) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if p.isClosed {
-		return errors.New("provider is closed")
-	}
-
 	scriptPath, err := getWorkerPath()
-	if err != nil {
-		return fmt.Errorf("azure initialization failed: %w", err)
-	}
-
-	log.Printf("Azure: Using Python worker at %s", scriptPath)
-
-	// "-u" flag for unbuffered output is crucial!
-	p.cmd = exec.CommandContext(ctx, "python3", "-u", scriptPath, "--key", p.subscriptionKey, "--region", p.region)
-
-	// 2. Pipes verbinden
-	p.stdin, err = p.cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-
-	stdout, err := p.cmd.StdoutPipe()
-	if err != nil {
-		return err
-	}
 
-	// Stderr auch durchleiten für Debugging (landet im Go Log)
-	p.cmd.Stderr = os.Stderr
-
-	// 3. Prozess starten
-	if err := p.cmd.Start(); err != nil {
-		return err
-	}
-	log.Println("Azure: Python worker started")
-
-	// 4. Lese-Loop in Goroutine starten
-	go p.readOutput(stdout)
-
-	return nil
-}
-
-func (p *Provider) readOutput(r io.Reader) {
-	scanner := bufio.NewScanner(r)
-
-	// Liest Zeile für Zeile (JSON) von Python
-	for scanner.Scan() {
-		line := scanner.Bytes()
-
-		var res pythonResult
-		if err := json.Unmarshal(line, &res); err != nil {
-			log.Printf("Azure: Failed to parse JSON from worker: %v", err)
-			continue
-		}
-
-		// Senden an Frontend
-		select {
-		case p.resChan <- transcription.TranscriptResult{
-			Text:      res.Text,
-			IsPartial: res.IsPartial,
-			Speaker:   res.Speaker,
-		}:
-		default:
-			// Drop frame if channel full
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Printf("Azure: Error reading from worker: %v", err)
-		// Optional: Error in errChan senden
-	}
-
-	log.Println("Azure: Output reader stopped")
-}
-
-func (p *Provider) SendAudio(data []byte) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if p.isClosed || p.stdin == nil {
-		return errors.New("azure worker not active")
+	return &Provider{
+		// "-u" flag for unbuffered output is crucial!
+		Provider: stdio.New(stdio.Config{
+			Name:        "Azure",
+			Command:     "python3",
+			Args:        []string{"-u", scriptPath, "--key", key, "--region", region},
+			AudioFormat: transcription.AudioPCM16,
+			Protocol:    stdio.ProtocolFramed,
+			// Netzwerkabbrüche beendet der Worker, er wird dann neu gestartet
+			MaxRestarts:   3,
+			RestartWindow: 5 * time.Minute,
+		}),
+		workerErr: err,
 	}
-
-	// Audio Bytes direkt an Python Stdin schreiben
-	_, err := p.stdin.Write(data)
-	return err
 }
 
-func (p *Provider) Close() error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if p.isClosed {
-		return nil
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	if p.workerErr != nil {
+		return fmt.Errorf("azure initialization failed: %w", p.workerErr)
 	}
-	p.isClosed = true
-
-	if p.stdin != nil {
-		p.stdin.Close()
-	}
-
-	if p.cmd != nil {
-		p.cmd.Wait()
-	}
-
-	log.Println("Azure: Python worker closed")
-	return nil
+	return p.Provider.Connect(ctx, opts)
 }
-
-func (p *Provider) ResultChan() <-chan transcription.TranscriptResult { return p.resChan }
-func (p *Provider) ErrorChan() <-chan error                           { return p.errChan }
@@ -0,0 +1,22 @@
+package transcription
+
+// AudioFormat names the audio encoding a provider expects from the host.
+type AudioFormat string
+
+const (
+	// AudioPCM16 is raw 16 kHz mono signed 16-bit little-endian PCM.
+	AudioPCM16 AudioFormat = "pcm_s16le_16k"
+	// AudioWebM is the WebM/Opus container produced by the browser's MediaRecorder.
+	AudioWebM AudioFormat = "webm"
+)
+
+// Capabilities describe the features a provider supports.
+type Capabilities struct {
+	Diarization bool `json:"diarization"`
+	Partials    bool `json:"partials"`
+	WordTimings bool `json:"word_timings"`
+	AutoDetect  bool `json:"auto_detect"`
+	// Languages lists the supported language codes. Empty means any language.
+	Languages   []string    `json:"languages,omitempty"`
+	AudioFormat AudioFormat `json:"audio_format,omitempty"`
+}
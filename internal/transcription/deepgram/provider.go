@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"strings"
+	"sync"
 
 	// Import SDK v3 based on your snippet
 	api "github.com/deepgram/deepgram-go-sdk/v3/pkg/api/listen/v1/websocket/interfaces"
@@ -17,39 +18,116 @@ import (
 	"github.com/joshuabeny1999/tolka/internal/transcription"
 )
 
-func init() {
-	client.InitWithDefault()
+// initSDK sets up the SDK's logging on first use. The SDK parses the command line
+// flags during init, so it must not run at import time (e.g. in test binaries).
+var initSDK sync.Once
+
+// Languages lists the language codes nova-3 can transcribe.
+var Languages = []string{
+	"de", "de-CH",
+	"en", "en-US", "en-GB", "en-AU", "en-IN",
+	"fr", "fr-CA",
+	"it", "es", "nl", "pt",
+}
+
+// MultiLanguages lists the languages nova-3 can switch between in multilingual mode.
+var MultiLanguages = []string{
+	"de", "en", "en-US", "en-GB",
+	"fr", "es", "it", "nl", "pt", "ja", "ru", "hi",
+}
+
+// Capabilities describes the features of the Deepgram provider.
+var Capabilities = transcription.Capabilities{
+	Diarization: true,
+	Partials:    true,
+	WordTimings: true,
+	AutoDetect:  true,
+	Languages:   Languages,
+	AudioFormat: transcription.AudioWebM,
+}
+
+// ValidateOptions checks the session options against the languages Deepgram supports.
+// With AutoDetect all candidates must be available in the multilingual model,
+// which only nova-3 offers.
+func ValidateOptions(opts transcription.Options, settings Settings) error {
+	if opts.AutoDetect {
+		if !settings.nova3() {
+			return fmt.Errorf("auto detection requires a nova-3 model, got %q", settings.Model)
+		}
+		return multiOptions(opts).Validate(MultiLanguages)
+	}
+	return opts.Validate(Languages)
+}
+
+// multiOptions maps regional candidates the multilingual model does not list to
+// their base language (de-CH -> de). In multilingual mode Deepgram only receives
+// "multi", so the region does not reach the API anyway.
+func multiOptions(opts transcription.Options) transcription.Options {
+	opts.Language = multiLanguage(opts.Language)
+	languages := make([]string, len(opts.Languages))
+	for i, lang := range opts.Languages {
+		languages[i] = multiLanguage(lang)
+	}
+	opts.Languages = languages
+	return opts
+}
+
+func multiLanguage(lang string) string {
+	for _, supported := range MultiLanguages {
+		if strings.EqualFold(supported, lang) {
+			return lang
+		}
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	return base
 }
 
 type Provider struct {
 	apiKey      string
+	settings    Settings
 	dgClient    *client.WSCallback
 	resChan     chan transcription.TranscriptResult
 	errChan     chan error
+	activity    chan transcription.SpeechEvent
 	inputWriter *io.PipeWriter // We write audio chunks here
 }
 
 // New creates a new Deepgram provider instance
-func New(apiKey string) *Provider {
+func New(apiKey string, settings Settings) *Provider {
+	initSDK.Do(client.InitWithDefault)
 	return &Provider{
-		apiKey:  apiKey,
-		resChan: make(chan transcription.TranscriptResult, 100),
-		errChan: make(chan error, 10),
+		apiKey:   apiKey,
+		settings: settings,
+		resChan:  make(chan transcription.TranscriptResult, 100),
+		errChan:  make(chan error, 10),
+		activity: make(chan transcription.SpeechEvent, 10),
 	}
 }
 
-func (p *Provider) Connect(ctx context.Context) error {
-	// 1. Setup Options (Swiss German, Nova-3, Interim)
+// liveOptions builds the stream options: session language, configured model and endpointing, interim results.
+func (p *Provider) liveOptions(opts transcription.Options) *interfaces.LiveTranscriptionOptions {
 	options := &interfaces.LiveTranscriptionOptions{
-		Model:          "nova-3",
-		Language:       "de-ch",
-		SmartFormat:    true,
+		Model:          p.settings.Model,
+		Language:       deepgramLanguage(opts),
+		SmartFormat:    p.settings.SmartFormat,
 		InterimResults: true,
-		Endpointing:    "500",
-		UtteranceEndMs: "1000",
-		Diarize:        true,
-		// Diarization: true, // Uncomment later when needed
+		VadEvents:      true, // SpeechStarted for speech_start
+		Endpointing:    p.settings.endpointing(),
+		UtteranceEndMs: p.settings.utteranceEnd(),
+		Diarize:        p.settings.Diarize,
 	}
+	// Session vocabulary: nova-3 takes key terms, older models only keyword boosting
+	if p.settings.nova3() {
+		options.Keyterm = opts.Vocabulary
+	} else {
+		options.Keywords = opts.Vocabulary
+	}
+	return options
+}
+
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	// 1. Setup Options
+	options := p.liveOptions(opts)
 
 	// 2. Create Callback to handle incoming messages
 	// We pass 'p' (the provider) to the callback so it can push to resChan
@@ -80,10 +158,19 @@ func (p *Provider) Connect(ctx context.Context) error {
 		// When Stream returns, the connection is done.
 	}()
 
-	log.Println("Deepgram: Connected and streaming started")
+	log.Printf("Deepgram: Connected and streaming started (language %s, model %s)", options.Language, options.Model)
 	return nil
 }
 
+// deepgramLanguage maps the session options onto Deepgram's language parameter.
+// "multi" enables code-switching between the supported languages.
+func deepgramLanguage(opts transcription.Options) string {
+	if opts.AutoDetect {
+		return "multi"
+	}
+	return strings.ToLower(opts.Language)
+}
+
 func (p *Provider) SendAudio(data []byte) error {
 	if p.inputWriter == nil {
 		return nil // Or error "not connected"
@@ -104,6 +191,10 @@ func (p *Provider) ErrorChan() <-chan error {
 	return p.errChan
 }
 
+func (p *Provider) ActivityChan() <-chan transcription.SpeechEvent {
+	return p.activity
+}
+
 func (p *Provider) Close() error {
 	// Closing the pipe writer signals EOF to Deepgram's Stream() method.
 	if p.inputWriter != nil {
@@ -122,34 +213,94 @@ func (p *Provider) Close() error {
 
 type deepgramCallback struct {
 	provider *Provider
+	// lastSpeaker is the speaker of the latest result, reported with UtteranceEnd
+	lastSpeaker string
 }
 
-// getDominantSpeaker is a helper function and returns the speaker label with the highest number of words
-func getDominantSpeaker(words []api.Word) string {
-	if len(words) == 0 {
-		return "Unknown"
+// splitBySpeaker cuts the words into runs of the same speaker. Words without
+// speaker (diarization off) form a single run.
+func splitBySpeaker(words []api.Word) [][]api.Word {
+	var runs [][]api.Word
+	start := 0
+	for i := 1; i <= len(words); i++ {
+		if i == len(words) || !sameSpeaker(words[i].Speaker, words[start].Speaker) {
+			runs = append(runs, words[start:i])
+			start = i
+		}
+	}
+	return runs
+}
+
+func sameSpeaker(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
+}
 
-	counts := make(map[int]int)
+// joinWords rebuilds the text of a segment from its (punctuated) words.
+func joinWords(words []api.Word) string {
+	parts := make([]string, 0, len(words))
 	for _, w := range words {
-		counts[*w.Speaker]++
+		text := w.PunctuatedWord
+		if text == "" {
+			text = w.Word
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
+
+// speakerLabel formats a Deepgram speaker index. Without diarization the index is nil.
+func speakerLabel(speaker *int) string {
+	if speaker == nil {
+		return ""
 	}
+	return fmt.Sprintf("Speaker %d", *speaker)
+}
 
-	maxCount := -1
-	dominantSpeakerID := -1
+// convertWords maps Deepgram words onto the provider-neutral word type
+func convertWords(words []api.Word) []transcription.Word {
+	if len(words) == 0 {
+		return nil
+	}
 
-	for speakerID, count := range counts {
-		if count > maxCount {
-			maxCount = count
-			dominantSpeakerID = speakerID
+	out := make([]transcription.Word, 0, len(words))
+	for _, w := range words {
+		text := w.PunctuatedWord
+		if text == "" {
+			text = w.Word
 		}
+		out = append(out, transcription.Word{
+			Text:       text,
+			Start:      w.Start,
+			End:        w.End,
+			Confidence: w.Confidence,
+			Speaker:    speakerLabel(w.Speaker),
+		})
 	}
+	return out
+}
 
-	if dominantSpeakerID == -1 {
-		return "Unknown"
+// detectedLanguage returns the language most words were recognized in.
+// Deepgram only tags words in multilingual mode, otherwise this is empty.
+func detectedLanguage(alternative api.Alternative) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, w := range alternative.Words {
+		if w.Language == "" {
+			continue
+		}
+		counts[w.Language]++
+		if counts[w.Language] > counts[best] {
+			best = w.Language
+		}
 	}
 
-	return fmt.Sprintf("Speaker %d", dominantSpeakerID)
+	if best == "" && len(alternative.Languages) > 0 {
+		return alternative.Languages[0]
+	}
+	return best
 }
 
 // Message is called when Deepgram sends a transcript
@@ -165,21 +316,56 @@ func (c *deepgramCallback) Message(mr *api.MessageResponse) error {
 		return nil
 	}
 
-	speakerLabel := getDominantSpeaker(alternative.Words)
+	runs := splitBySpeaker(alternative.Words)
+	if len(runs) <= 1 {
+		// Ein Sprecher: Transcript und Zeitfenster der Nachricht unverändert übernehmen
+		var speaker *int
+		if len(alternative.Words) > 0 {
+			speaker = alternative.Words[0].Speaker
+		}
+		c.emit(transcription.TranscriptResult{
+			Text:      transcript,
+			IsPartial: !mr.IsFinal,
+			Speaker:   segmentSpeaker(speaker),
+			Start:     mr.Start,
+			End:       mr.Start + mr.Duration,
+			Words:     convertWords(alternative.Words),
+			Language:  detectedLanguage(alternative),
+		})
+		return nil
+	}
+
+	// Sprecherwechsel innerhalb der Nachricht: ein Ergebnis pro Sprecher
+	for i, words := range runs {
+		c.emit(transcription.TranscriptResult{
+			Text:      joinWords(words),
+			IsPartial: !mr.IsFinal,
+			Speaker:   segmentSpeaker(words[0].Speaker),
+			Start:     words[0].Start,
+			End:       words[len(words)-1].End,
+			Words:     convertWords(words),
+			Language:  detectedLanguage(api.Alternative{Words: words}),
+			Segment:   i,
+		})
+	}
+	return nil
+}
 
-	result := transcription.TranscriptResult{
-		Text:      transcript,
-		IsPartial: !mr.IsFinal,
-		Speaker:   speakerLabel,
+// segmentSpeaker labels a segment, "Unknown" without diarization.
+func segmentSpeaker(speaker *int) string {
+	if label := speakerLabel(speaker); label != "" {
+		return label
 	}
+	return "Unknown"
+}
 
+func (c *deepgramCallback) emit(result transcription.TranscriptResult) {
+	c.lastSpeaker = result.Speaker
 	select {
 	case c.provider.resChan <- result:
 	default:
 		log.Println("Deepgram: Warning - Result channel full")
 	}
-
-	return nil
 }
 
 // Boilerplate implementation for other required interface methods
@@ -195,6 +381,26 @@ func (c *deepgramCallback) Error(r *api.ErrorResponse) error {
 	}
 	return nil
 }
-func (c *deepgramCallback) UnhandledEvent([]byte) error                    { return nil }
-func (c *deepgramCallback) SpeechStarted(*api.SpeechStartedResponse) error { return nil }
-func (c *deepgramCallback) UtteranceEnd(*api.UtteranceEndResponse) error   { return nil }
+func (c *deepgramCallback) UnhandledEvent([]byte) error { return nil }
+
+// SpeechStarted arrives before the first words; the speaker is not known yet
+func (c *deepgramCallback) SpeechStarted(r *api.SpeechStartedResponse) error {
+	c.reportActivity(transcription.SpeechEvent{Type: transcription.SpeechStart, Time: r.Timestamp})
+	return nil
+}
+
+func (c *deepgramCallback) UtteranceEnd(r *api.UtteranceEndResponse) error {
+	c.reportActivity(transcription.SpeechEvent{
+		Type:    transcription.SpeechEnd,
+		Speaker: c.lastSpeaker,
+		Time:    r.LastWordEnd,
+	})
+	return nil
+}
+
+func (c *deepgramCallback) reportActivity(event transcription.SpeechEvent) {
+	select {
+	case c.provider.activity <- event:
+	default:
+	}
+}
@@ -0,0 +1,75 @@
+package deepgram
+
+import (
+	"testing"
+
+	api "github.com/deepgram/deepgram-go-sdk/v3/pkg/api/listen/v1/websocket/interfaces"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+func word(text string, start float64, speaker *int) api.Word {
+	return api.Word{Word: text, PunctuatedWord: text, Start: start, End: start + 0.3, Speaker: speaker}
+}
+
+func speaker(id int) *int { return &id }
+
+func collect(t *testing.T, words []api.Word, final bool) []transcription.TranscriptResult {
+	t.Helper()
+	provider := &Provider{resChan: make(chan transcription.TranscriptResult, 10)}
+	callback := &deepgramCallback{provider: provider}
+
+	mr := &api.MessageResponse{IsFinal: final, Start: 1, Duration: 2}
+	mr.Channel.Alternatives = []api.Alternative{{Transcript: "Hallo zusammen", Words: words}}
+	if err := callback.Message(mr); err != nil {
+		t.Fatalf("Message failed: %v", err)
+	}
+	close(provider.resChan)
+
+	var results []transcription.TranscriptResult
+	for res := range provider.resChan {
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestMessage_SplitsBySpeaker(t *testing.T) {
+	results := collect(t, []api.Word{
+		word("Kommst", 1.0, speaker(0)),
+		word("du?", 1.3, speaker(0)),
+		word("Ja.", 1.7, speaker(1)),
+		word("Gut.", 2.2, speaker(0)),
+	}, true)
+
+	expected := []transcription.TranscriptResult{
+		{Text: "Kommst du?", Speaker: "Speaker 0", Start: 1.0, End: 1.6, Segment: 0},
+		{Text: "Ja.", Speaker: "Speaker 1", Start: 1.7, End: 2.0, Segment: 1},
+		{Text: "Gut.", Speaker: "Speaker 0", Start: 2.2, End: 2.5, Segment: 2},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d segments, got %+v", len(expected), results)
+	}
+	for i, want := range expected {
+		got := results[i]
+		if got.Text != want.Text || got.Speaker != want.Speaker || got.Start != want.Start ||
+			got.End != want.End || got.Segment != want.Segment || got.IsPartial {
+			t.Errorf("Segment %d: expected %+v, got %+v", i, want, got)
+		}
+		if len(got.Words) == 0 || got.Words[0].Speaker != want.Speaker {
+			t.Errorf("Segment %d: words not split correctly: %+v", i, got.Words)
+		}
+	}
+}
+
+func TestMessage_WithoutDiarization(t *testing.T) {
+	// Ohne Diarization fehlt der Speaker-Pointer, das darf nicht paniken
+	results := collect(t, []api.Word{word("Hallo", 1.0, nil), word("zusammen", 1.4, nil)}, false)
+
+	if len(results) != 1 {
+		t.Fatalf("Expected one result, got %+v", results)
+	}
+	got := results[0]
+	if got.Text != "Hallo zusammen" || got.Speaker != "Unknown" || !got.IsPartial || got.Start != 1 || got.End != 3 {
+		t.Errorf("Unexpected result: %+v", got)
+	}
+}
@@ -0,0 +1,113 @@
+package deepgram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Settings are the tunable parameters of a Deepgram stream.
+type Settings struct {
+	Model string
+	// Endpointing is the silence in milliseconds that finalizes a result. Zero disables it.
+	Endpointing int
+	// UtteranceEnd is the gap in milliseconds between words that ends an utterance. Zero disables it.
+	UtteranceEnd int
+	SmartFormat  bool
+	Diarize      bool
+}
+
+// DefaultSettings are used unless the deployment or the session overrides them.
+var DefaultSettings = Settings{
+	Model:        "nova-3",
+	Endpointing:  500,
+	UtteranceEnd: 1000,
+	SmartFormat:  true,
+	Diarize:      true,
+}
+
+// modelFamilies lists the streaming models, variants like "nova-2-meeting" are accepted too.
+var modelFamilies = []string{"nova-3", "nova-2", "nova", "enhanced", "base"}
+
+// ParseSettings applies params (keys model, endpointing, utterance_end, smart_format
+// and diarize) on top of DefaultSettings and validates the result.
+func ParseSettings(params map[string]string) (Settings, error) {
+	s := DefaultSettings
+	for key, value := range params {
+		var err error
+		switch key {
+		case "model":
+			s.Model = strings.TrimSpace(value)
+		case "endpointing":
+			s.Endpointing, err = strconv.Atoi(value)
+		case "utterance_end":
+			s.UtteranceEnd, err = strconv.Atoi(value)
+		case "smart_format":
+			s.SmartFormat, err = strconv.ParseBool(value)
+		case "diarize":
+			s.Diarize, err = strconv.ParseBool(value)
+		default:
+			return s, fmt.Errorf("unknown deepgram option %q", key)
+		}
+		if err != nil {
+			return s, fmt.Errorf("invalid value %q for deepgram option %s", value, key)
+		}
+	}
+	return s, s.Validate()
+}
+
+// Validate checks the settings against the limits of the streaming API.
+func (s Settings) Validate() error {
+	if !knownModel(s.Model) {
+		return fmt.Errorf("unknown deepgram model %q", s.Model)
+	}
+	if s.Endpointing != 0 && (s.Endpointing < 10 || s.Endpointing > 10000) {
+		return fmt.Errorf("endpointing must be 0 (off) or between 10 and 10000 ms, got %d", s.Endpointing)
+	}
+	// Deepgram sendet UtteranceEnd erst ab 1000 ms zuverlässig
+	if s.UtteranceEnd != 0 && (s.UtteranceEnd < 1000 || s.UtteranceEnd > 5000) {
+		return fmt.Errorf("utterance_end must be 0 (off) or between 1000 and 5000 ms, got %d", s.UtteranceEnd)
+	}
+	return nil
+}
+
+// nova3 reports whether the model supports key terms and multilingual detection.
+func (s Settings) nova3() bool {
+	return s.Model == "nova-3" || strings.HasPrefix(s.Model, "nova-3-")
+}
+
+func knownModel(model string) bool {
+	for _, family := range modelFamilies {
+		if model == family || strings.HasPrefix(model, family+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// Params returns the settings in the form of ParseSettings, e.g. for session metadata.
+func (s Settings) Params() map[string]string {
+	return map[string]string{
+		"model":         s.Model,
+		"endpointing":   strconv.Itoa(s.Endpointing),
+		"utterance_end": strconv.Itoa(s.UtteranceEnd),
+		"smart_format":  strconv.FormatBool(s.SmartFormat),
+		"diarize":       strconv.FormatBool(s.Diarize),
+	}
+}
+
+// endpointing formats the value of the endpointing query parameter.
+func (s Settings) endpointing() string {
+	if s.Endpointing == 0 {
+		return "false"
+	}
+	return strconv.Itoa(s.Endpointing)
+}
+
+// utteranceEnd formats the value of the utterance_end_ms query parameter; empty omits it.
+func (s Settings) utteranceEnd() string {
+	if s.UtteranceEnd == 0 {
+		return ""
+	}
+	return strconv.Itoa(s.UtteranceEnd)
+}
@@ -0,0 +1,89 @@
+package deepgram
+
+import (
+	"testing"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+func TestParseSettings(t *testing.T) {
+	s, err := ParseSettings(map[string]string{
+		"model":         "nova-2-meeting",
+		"endpointing":   "0",
+		"utterance_end": "1500",
+		"diarize":       "false",
+	})
+	if err != nil {
+		t.Fatalf("ParseSettings failed: %v", err)
+	}
+
+	want := Settings{Model: "nova-2-meeting", Endpointing: 0, UtteranceEnd: 1500, SmartFormat: true, Diarize: false}
+	if s != want {
+		t.Errorf("Expected %+v, got %+v", want, s)
+	}
+	if s.endpointing() != "false" || s.utteranceEnd() != "1500" {
+		t.Errorf("Unexpected query values %q %q", s.endpointing(), s.utteranceEnd())
+	}
+
+	roundTrip, err := ParseSettings(s.Params())
+	if err != nil || roundTrip != s {
+		t.Errorf("Params round trip failed: %+v (%v)", roundTrip, err)
+	}
+}
+
+func TestParseSettings_Invalid(t *testing.T) {
+	invalid := []map[string]string{
+		{"model": "whisper-large"},
+		{"endpointing": "5"},
+		{"endpointing": "fast"},
+		{"utterance_end": "500"},
+		{"smart_format": "maybe"},
+		{"punctuate": "true"},
+	}
+	for _, params := range invalid {
+		if _, err := ParseSettings(params); err == nil {
+			t.Errorf("Expected error for %v", params)
+		}
+	}
+}
+
+func TestValidateOptions_AutoDetectModel(t *testing.T) {
+	opts := transcription.Options{Language: "de", Languages: []string{"en"}, AutoDetect: true}
+
+	if err := ValidateOptions(opts, DefaultSettings); err != nil {
+		t.Errorf("Expected nova-3 to support auto detection, got %v", err)
+	}
+	if err := ValidateOptions(opts, Settings{Model: "nova-2"}); err == nil {
+		t.Error("Expected error for auto detection with nova-2")
+	}
+}
+
+func TestValidateOptions_AutoDetectDefaultLanguage(t *testing.T) {
+	// Schweizerdeutsch mit Englisch gemischt, ohne explizite Sprache
+	opts := transcription.Options{Languages: []string{"en-US"}, AutoDetect: true}.WithDefaults()
+	if err := ValidateOptions(opts, DefaultSettings); err != nil {
+		t.Errorf("Expected de-CH to map to de in multilingual mode, got %v", err)
+	}
+
+	opts = transcription.Options{Language: "de-CH", Languages: []string{"sv-SE"}, AutoDetect: true}
+	if err := ValidateOptions(opts, DefaultSettings); err == nil {
+		t.Error("Expected error for a language the multilingual model lacks")
+	}
+}
+
+func TestLiveOptions_Vocabulary(t *testing.T) {
+	opts := transcription.Options{Language: "de-CH", Vocabulary: []string{"Tolka"}}
+
+	options := New("key", DefaultSettings).liveOptions(opts)
+	if len(options.Keyterm) != 1 || len(options.Keywords) != 0 {
+		t.Errorf("Expected key terms for nova-3, got %+v", options)
+	}
+
+	// Keyterm gibt es nur bei nova-3
+	older := DefaultSettings
+	older.Model = "nova-2-meeting"
+	options = New("key", older).liveOptions(opts)
+	if len(options.Keyterm) != 0 || len(options.Keywords) != 1 || options.Keywords[0] != "Tolka" {
+		t.Errorf("Expected keywords for nova-2, got %+v", options)
+	}
+}
@@ -0,0 +1,55 @@
+package transcription
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Failure classes reported by providers. Use errors.Is to check a reported error.
+var (
+	ErrAuth         = errors.New("authentication failed")
+	ErrQuota        = errors.New("quota exceeded")
+	ErrNetwork      = errors.New("network error")
+	ErrService      = errors.New("service error")
+	ErrWorkerExited = errors.New("worker exited")
+
+	// ErrRestartsExhausted is a worker exit after the provider used up its own restart
+	// budget. It is permanent, so wrappers fail over instead of starting the worker again.
+	ErrRestartsExhausted = fmt.Errorf("%w: restart budget exhausted", ErrWorkerExited)
+)
+
+// ProviderError is reported on ErrorChan with the failure class and details.
+type ProviderError struct {
+	Provider string
+	Kind     error // One of the Err* failure classes
+	Detail   string
+}
+
+func (e *ProviderError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Provider, e.Kind, e.Detail)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Kind }
+
+// ErrorKind maps the kind names used by workers ("auth", "quota", "network") to failure classes.
+func ErrorKind(name string) error {
+	switch name {
+	case "auth":
+		return ErrAuth
+	case "quota":
+		return ErrQuota
+	case "network":
+		return ErrNetwork
+	default:
+		return ErrService
+	}
+}
+
+// IsPermanent reports whether retrying cannot help, e.g. invalid credentials, exhausted
+// quota or a worker that keeps crashing.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrAuth) || errors.Is(err, ErrQuota) || errors.Is(err, ErrRestartsExhausted)
+}
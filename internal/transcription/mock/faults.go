@@ -0,0 +1,120 @@
+package mock
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Faults beschreibt das Fehlverhalten einer Session, um Room und Frontend
+// gegen schlecht funktionierende Provider zu testen. Zeiten beziehen sich auf
+// die simulierte Stream-Zeit (0.25s pro Audio-Paket).
+type Faults struct {
+	FailConnect bool    // Connect schlägt fehl
+	ErrorAfter  float64 // Sekunden bis zum Fehler auf ErrorChan, 0 = nie
+	ErrorKind   string  // auth, quota, network oder service
+	CloseAfter  float64 // Sekunden bis der Result-Channel geschlossen wird, 0 = nie
+
+	SpikeEvery int           // Jedes n-te Ergebnis kommt verspätet
+	SpikeDelay time.Duration // Verzögerung der Latenzspitze
+
+	Reorder bool // Partials überholen sich gegenseitig
+
+	FloodEvery int // Jedes n-te Ergebnis kommt als Burst
+	FloodSize  int // Anzahl Kopien pro Burst, höchstens outboxSize
+}
+
+// DefaultFaults ist eine Session ohne Fehlverhalten
+var DefaultFaults = Faults{
+	ErrorKind:  "network",
+	SpikeDelay: 2 * time.Second,
+	FloodSize:  50,
+}
+
+// Options liefert alle Session-Optionen des Mocks mit ihren Standardwerten:
+// "script" (siehe FindScript) und die Fehlerinjektion (siehe ParseFaults).
+func Options() map[string]string {
+	return map[string]string{
+		"script":       "",
+		"fail_connect": strconv.FormatBool(DefaultFaults.FailConnect),
+		"error_after":  strconv.FormatFloat(DefaultFaults.ErrorAfter, 'f', -1, 64),
+		"error_kind":   DefaultFaults.ErrorKind,
+		"close_after":  strconv.FormatFloat(DefaultFaults.CloseAfter, 'f', -1, 64),
+		"spike_every":  strconv.Itoa(DefaultFaults.SpikeEvery),
+		"spike_delay":  DefaultFaults.SpikeDelay.String(),
+		"reorder":      strconv.FormatBool(DefaultFaults.Reorder),
+		"flood_every":  strconv.Itoa(DefaultFaults.FloodEvery),
+		"flood_size":   strconv.Itoa(DefaultFaults.FloodSize),
+	}
+}
+
+// ParseFaults wendet die Session-Optionen auf DefaultFaults an und prüft das Ergebnis.
+// "script" wird übersprungen, unbekannte Schlüssel werden abgelehnt.
+func ParseFaults(params map[string]string) (Faults, error) {
+	f := DefaultFaults
+	for key, value := range params {
+		var err error
+		switch key {
+		case "script":
+		case "fail_connect":
+			f.FailConnect, err = strconv.ParseBool(value)
+		case "error_after":
+			f.ErrorAfter, err = strconv.ParseFloat(value, 64)
+		case "error_kind":
+			f.ErrorKind = value
+		case "close_after":
+			f.CloseAfter, err = strconv.ParseFloat(value, 64)
+		case "spike_every":
+			f.SpikeEvery, err = strconv.Atoi(value)
+		case "spike_delay":
+			f.SpikeDelay, err = time.ParseDuration(value)
+		case "reorder":
+			f.Reorder, err = strconv.ParseBool(value)
+		case "flood_every":
+			f.FloodEvery, err = strconv.Atoi(value)
+		case "flood_size":
+			f.FloodSize, err = strconv.Atoi(value)
+		default:
+			return f, fmt.Errorf("unknown mock option %q", key)
+		}
+		if err != nil {
+			return f, fmt.Errorf("invalid value %q for mock option %s", value, key)
+		}
+	}
+	return f, f.Validate()
+}
+
+// Validate prüft Wertebereiche der Fehlerinjektion.
+func (f Faults) Validate() error {
+	switch {
+	case f.ErrorAfter < 0 || f.CloseAfter < 0:
+		return fmt.Errorf("error_after and close_after must not be negative")
+	case f.SpikeEvery < 0 || f.FloodEvery < 0:
+		return fmt.Errorf("spike_every and flood_every must not be negative")
+	case f.SpikeDelay < 0 || f.SpikeDelay > time.Minute:
+		return fmt.Errorf("spike_delay must be between 0 and 1m")
+	case f.FloodSize < 1 || f.FloodSize > outboxSize:
+		return fmt.Errorf("flood_size must be between 1 and %d", outboxSize)
+	}
+	switch f.ErrorKind {
+	case "auth", "quota", "network", "service":
+	default:
+		return fmt.Errorf("error_kind must be auth, quota, network or service")
+	}
+	return nil
+}
+
+// async ist wahr, wenn Ergebnisse über die Auslieferungs-Goroutine laufen müssen
+func (f Faults) async() bool {
+	return f.SpikeEvery > 0 || f.FloodEvery > 0
+}
+
+func (f Faults) error(detail string) error {
+	return &transcription.ProviderError{
+		Provider: "Mock",
+		Kind:     transcription.ErrorKind(f.ErrorKind),
+		Detail:   detail,
+	}
+}
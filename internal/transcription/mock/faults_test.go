@@ -0,0 +1,176 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/mock"
+)
+
+func TestParseFaults(t *testing.T) {
+	faults, err := mock.ParseFaults(map[string]string{
+		"script":      "standup",
+		"error_after": "2.5",
+		"error_kind":  "quota",
+		"spike_delay": "150ms",
+		"reorder":     "true",
+	})
+	if err != nil {
+		t.Fatalf("ParseFaults failed: %v", err)
+	}
+	if faults.ErrorAfter != 2.5 || faults.ErrorKind != "quota" || faults.SpikeDelay != 150*time.Millisecond || !faults.Reorder {
+		t.Errorf("Unexpected faults: %+v", faults)
+	}
+	if faults.FloodSize != mock.DefaultFaults.FloodSize {
+		t.Errorf("Expected default flood size, got %d", faults.FloodSize)
+	}
+
+	for _, params := range []map[string]string{
+		{"unknown": "1"},
+		{"error_after": "soon"},
+		{"error_after": "-1"},
+		{"error_kind": "timeout"},
+		{"flood_size": "0"},
+		{"flood_size": "10000"},
+		{"spike_delay": "1h"},
+	} {
+		if _, err := mock.ParseFaults(params); err == nil {
+			t.Errorf("Expected error for %v", params)
+		}
+	}
+
+	// Jede Fehler-Option ist als Session-Option deklariert
+	if _, err := mock.ParseFaults(mock.Options()); err != nil {
+		t.Errorf("Default options rejected: %v", err)
+	}
+}
+
+func TestFaults_FailConnect(t *testing.T) {
+	provider, err := mock.FromParams("", map[string]string{"fail_connect": "true", "error_kind": "auth"})
+	if err != nil {
+		t.Fatalf("FromParams failed: %v", err)
+	}
+	defer provider.Close()
+
+	err = provider.Connect(context.Background(), transcription.Options{})
+	if !errors.Is(err, transcription.ErrAuth) {
+		t.Errorf("Expected ErrAuth, got %v", err)
+	}
+}
+
+func TestFaults_ErrorAndClose(t *testing.T) {
+	provider, err := mock.FromParams("", map[string]string{"error_after": "0.5", "close_after": "1"})
+	if err != nil {
+		t.Fatalf("FromParams failed: %v", err)
+	}
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+	select {
+	case err := <-provider.ErrorChan():
+		if !errors.Is(err, transcription.ErrNetwork) {
+			t.Errorf("Expected ErrNetwork, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for injected error")
+	}
+
+	for i := 0; i < 2; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+	results := 0
+	for range provider.ResultChan() {
+		results++
+	}
+	if results != 2 {
+		t.Errorf("Expected 2 results before the channel closed, got %d", results)
+	}
+
+	// Weiteres Audio und Close dürfen nach dem Schliessen nicht paniken
+	_ = provider.SendAudio([]byte("fake-audio-data"))
+	if err := provider.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestFaults_Reorder(t *testing.T) {
+	provider, err := mock.FromParams("", map[string]string{"reorder": "true"})
+	if err != nil {
+		t.Fatalf("FromParams failed: %v", err)
+	}
+	defer provider.Close()
+
+	for i := 0; i < 4; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+
+	// Das erste Partial wird vom zweiten überholt
+	for _, want := range []string{"Hallo zusammen,", "Hallo"} {
+		select {
+		case result := <-provider.ResultChan():
+			if result.Text != want {
+				t.Errorf("Expected '%s', got '%s'", want, result.Text)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for '%s'", want)
+		}
+	}
+}
+
+func TestFaults_FloodAndSpike(t *testing.T) {
+	provider, err := mock.FromParams("", map[string]string{
+		"flood_every": "1",
+		"flood_size":  "5",
+		"spike_every": "1",
+		"spike_delay": "50ms",
+	})
+	if err != nil {
+		t.Fatalf("FromParams failed: %v", err)
+	}
+	defer provider.Close()
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+
+	for i := 0; i < 5; i++ {
+		select {
+		case result := <-provider.ResultChan():
+			if result.Text != "Hallo" {
+				t.Errorf("Expected flooded copy of 'Hallo', got '%s'", result.Text)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for copy %d", i+1)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 5*50*time.Millisecond {
+		t.Errorf("Expected every copy to be delayed, took only %v", elapsed)
+	}
+}
+
+func TestFaults_SendAudioAfterClose(t *testing.T) {
+	provider, err := mock.FromParams("", map[string]string{"error_after": "0.1"})
+	if err != nil {
+		t.Fatalf("FromParams failed: %v", err)
+	}
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	provider.Close()
+
+	// Der injizierte Fehler darf nicht in den geschlossenen errChan gehen
+	if err := provider.SendAudio(make([]byte, 100)); err == nil {
+		t.Error("Expected error after Close")
+	}
+}
@@ -2,17 +2,26 @@ package mock
 
 import (
 	"context"
+	"errors"
 	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/joshuabeny1999/tolka/internal/transcription"
 )
 
 // ScriptLine repräsentiert eine Zeile im Dialog-Skript
 type ScriptLine struct {
-	Speaker string
-	Text    string
+	Speaker  string `json:"speaker" yaml:"speaker"`
+	Text     string `json:"text" yaml:"text"`
+	Language string `json:"language,omitempty" yaml:"language"` // Leer = Sprache der Session
+	// WordsPerSecond ist das Sprechtempo, 0 = zwei Wörter pro Sekunde
+	WordsPerSecond float64 `json:"words_per_second,omitempty" yaml:"words_per_second"`
+	// Pause ist die Stille in Sekunden vor der Zeile
+	Pause float64 `json:"pause,omitempty" yaml:"pause"`
+	// Partials steuert die Zwischenergebnisse: "words" (Standard) oder "none"
+	Partials string `json:"partials,omitempty" yaml:"partials"`
 }
 
 // Definiertes Gesprächsszenario für den Mock
@@ -25,29 +34,132 @@ var conversationScript = []ScriptLine{
 	{Speaker: "Speaker 1", Text: "Perfekt, dann lass uns das später mergen."},
 }
 
+// Gemischtsprachiges Szenario für Sessions mit automatischer Spracherkennung
+var codeSwitchingScript = []ScriptLine{
+	{Speaker: "Speaker 1", Text: "Grüezi mitenand, fanged mer a?", Language: "de-CH"},
+	{Speaker: "Speaker 2", Text: "Ja gerne. Ich habe die Folien vorbereitet.", Language: "de-DE"},
+	{Speaker: "Speaker 3", Text: "Sorry, could we do this in English today?", Language: "en-US"},
+	{Speaker: "Speaker 1", Text: "Sure, no problem. Let's start with the agenda.", Language: "en-US"},
+	{Speaker: "Speaker 2", Text: "Das isch super, merci viumau.", Language: "de-CH"},
+}
+
+// Capabilities beschreibt den Mock. Er akzeptiert jede Sprache.
+var Capabilities = transcription.Capabilities{
+	Diarization: true,
+	Partials:    true,
+	WordTimings: true,
+	AutoDetect:  true,
+}
+
+// packetDuration ist die angenommene Audiodauer pro SendAudio-Aufruf.
+// Daraus berechnet der Mock die Wort-Timings.
+const packetDuration = 0.25
+
 // Provider implementiert transcription.Service
 type Provider struct {
-	resChan chan transcription.TranscriptResult
-	errChan chan error
+	resChan  chan transcription.TranscriptResult
+	errChan  chan error
+	activity chan transcription.SpeechEvent
+
+	script      []ScriptLine
+	fixedScript bool // Per Session gewählt, Auto-Detect ersetzt es nicht
+	vocabulary  []string
 
 	// Simulation state
 	mu           sync.Mutex
 	scriptIdx    int      // Welcher Satz ist dran?
 	wordIdx      int      // Welches Wort im Satz sind wir?
-	tickCounter  int      // Simuliert Zeitfortschritt basierend auf Audio-Paketen
 	currentWords []string // Cache für die Wörter des aktuellen Satzes
+	lineStart    float64  // Beginn des aktuellen Satzes (nach der Pause)
+	lineStarted  bool     // speech_start für den aktuellen Satz gesendet
+
+	clock     float64              // Simulierte Stream-Zeit in Sekunden
+	lastWord  float64              // Ende des zuletzt gesendeten Wortes
+	lineWords []transcription.Word // Bisher gesendete Wörter des aktuellen Satzes
+
+	// Fehlerinjektion (siehe Faults)
+	faults    Faults
+	outbox    chan outgoing // Nur bei Latenzspitzen und Bursts, sonst direkt in resChan
+	done      chan struct{}
+	wg        sync.WaitGroup
+	delivered int                             // Anzahl ausgelieferter Ergebnisse
+	held      *transcription.TranscriptResult // Zurückgehaltenes Partial (Reorder)
+	errorSent bool
+	resClosed bool
+	isClosed  bool
+}
+
+// outboxSize ist die Kapazität der outbox, ein Burst muss vollständig hineinpassen
+const outboxSize = 1000
+
+// outgoing ist ein Ergebnis auf dem Weg zur Auslieferungs-Goroutine
+type outgoing struct {
+	result transcription.TranscriptResult
+	delay  time.Duration
 }
 
 // New erstellt eine neue Mock-Instanz
 func New() *Provider {
 	return &Provider{
-		resChan: make(chan transcription.TranscriptResult, 100),
-		errChan: make(chan error, 10),
+		resChan:  make(chan transcription.TranscriptResult, 100),
+		errChan:  make(chan error, 10),
+		activity: make(chan transcription.SpeechEvent, 10),
+		done:     make(chan struct{}),
+		script:   conversationScript,
+		faults:   DefaultFaults,
 	}
 }
 
-func (p *Provider) Connect(ctx context.Context) error {
-	log.Println("Mock: Connected and ready to simulate conversation")
+// NewScripted erstellt einen Mock, der das gegebene Skript abspielt (siehe LoadScript)
+func NewScripted(script []ScriptLine) *Provider {
+	p := New()
+	p.script = script
+	p.fixedScript = true
+	return p
+}
+
+// FromParams erstellt einen Mock aus den Session-Optionen (Skript und Fehlerinjektion).
+// Skriptnamen werden in scriptDir gesucht, siehe FindScript.
+func FromParams(scriptDir string, params map[string]string) (*Provider, error) {
+	faults, err := ParseFaults(params)
+	if err != nil {
+		return nil, err
+	}
+
+	p := New()
+	if name := params["script"]; name != "" {
+		script, err := FindScript(scriptDir, name)
+		if err != nil {
+			return nil, err
+		}
+		p = NewScripted(script)
+	}
+	p.faults = faults
+	return p, nil
+}
+
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.faults.FailConnect {
+		return p.faults.error("injected connect failure")
+	}
+	if p.faults.async() && p.outbox == nil {
+		p.outbox = make(chan outgoing, outboxSize)
+		p.wg.Add(1)
+		go p.deliverLoop()
+	}
+
+	if opts.AutoDetect && !p.fixedScript {
+		p.script = codeSwitchingScript
+	}
+
+	if len(opts.Vocabulary) > 0 {
+		p.echoVocabulary(opts.Vocabulary)
+	}
+
+	log.Printf("Mock: Connected and ready to simulate conversation (language %s, auto detect %t)", opts.Language, opts.AutoDetect)
 	return nil
 }
 
@@ -57,32 +169,82 @@ func (p *Provider) SendAudio(data []byte) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.isClosed {
+		return errors.New("provider is closed") // errChan ist bereits geschlossen
+	}
+
+	p.clock += packetDuration
+
+	// Bei schnellem Tempo fallen mehrere Wörter in ein Paket
+	for p.advance() {
+	}
+
+	if p.faults.ErrorAfter > 0 && p.clock >= p.faults.ErrorAfter && !p.errorSent {
+		p.errorSent = true
+		select {
+		case p.errChan <- p.faults.error("injected stream error"):
+		default:
+		}
+	}
+	if p.faults.CloseAfter > 0 && p.clock >= p.faults.CloseAfter && !p.resClosed {
+		log.Println("Mock: Closing result channel (injected fault)")
+		p.closeResults()
+	}
+	return nil
+}
+
+// advance sendet das nächste Wort, sobald es laut Tempo fällig ist.
+// Liefert false, wenn bis zum aktuellen Zeitpunkt nichts mehr zu tun ist.
+func (p *Provider) advance() bool {
 	// 1. Initialisierung des aktuellen Satzes, falls nötig
 	if p.currentWords == nil {
-		if p.scriptIdx >= len(conversationScript) {
+		if p.scriptIdx >= len(p.script) {
 			p.scriptIdx = 0 // Loop script from beginning
 		}
-		line := conversationScript[p.scriptIdx]
+		line := p.script[p.scriptIdx]
 		p.currentWords = strings.Fields(line.Text)
 		p.wordIdx = 0
+		p.lineStart = p.lastWord + line.Pause
+		p.lineStarted = false
 	}
 
-	// 2. Zeit verlangsamen (Throttle)
-	// Wir wollen nicht bei jedem kleinen Audio-Paket ein neues Wort senden,
-	// sondern z.B. nur jedes 3. Paket (simuliert Sprechgeschwindigkeit).
-	p.tickCounter++
-	if p.tickCounter < 2 {
-		return nil
+	currentLine := p.script[p.scriptIdx]
+
+	// 2. Pause vor dem Satz abwarten
+	if p.clock < p.lineStart {
+		return false
+	}
+	if !p.lineStarted {
+		// Neuer Satz: Sprecher ist sofort bekannt
+		p.reportActivity(transcription.SpeechEvent{Type: transcription.SpeechStart, Speaker: currentLine.Speaker, Time: p.lineStart})
+		p.lineStarted = true
 	}
-	p.tickCounter = 0
 
-	// 3. Simulations-Logik
-	currentLine := conversationScript[p.scriptIdx]
+	// 3. Tempo: Das nächste Wort endet erst nach 1/WordsPerSecond Sekunden
+	wps := currentLine.WordsPerSecond
+	if wps == 0 {
+		wps = defaultWordsPerSecond
+	}
+	wordEnd := p.lineStart + float64(p.wordIdx+1)/wps
+	if p.clock < wordEnd {
+		return false
+	}
 
 	// Wir fügen ein Wort hinzu
+	p.lineWords = append(p.lineWords, transcription.Word{
+		Text:       p.currentWords[p.wordIdx],
+		Start:      p.lineStart + float64(p.wordIdx)/wps,
+		End:        wordEnd,
+		Confidence: 1.0,
+		Speaker:    currentLine.Speaker,
+	})
+	p.lastWord = wordEnd
 	p.wordIdx++
 
 	isEndOfSentence := p.wordIdx >= len(p.currentWords)
+	if !isEndOfSentence && currentLine.Partials == PartialsNone {
+		return true
+	}
 
 	// Konstruiere den aktuellen Text-Stand (Partial)
 	partialText := strings.Join(p.currentWords[:p.wordIdx], " ")
@@ -91,6 +253,10 @@ func (p *Provider) SendAudio(data []byte) error {
 		Speaker:   currentLine.Speaker,
 		Text:      partialText, // Bei Partial schicken wir den bisherigen Satzaufbau
 		IsPartial: !isEndOfSentence,
+		Start:     p.lineWords[0].Start,
+		End:       wordEnd,
+		Words:     append([]transcription.Word(nil), p.lineWords...),
+		Language:  currentLine.Language,
 	}
 
 	// Wenn der Satz zu Ende ist, senden wir den finalen Text
@@ -103,21 +269,129 @@ func (p *Provider) SendAudio(data []byte) error {
 		// Reset für den nächsten Satz
 		p.scriptIdx++
 		p.currentWords = nil
+		p.lineWords = nil
 		p.wordIdx = 0
 
+		p.reportActivity(transcription.SpeechEvent{Type: transcription.SpeechEnd, Speaker: currentLine.Speaker, Time: wordEnd})
 		log.Printf("Mock: Sentence finished by %s", currentLine.Speaker)
 	}
 
-	// 4. Senden (Non-blocking select um Deadlocks zu vermeiden)
+	// 4. Senden
+	p.deliver(result)
+	return true
+}
+
+// deliver sendet ein Ergebnis unter Berücksichtigung der Fehlerinjektion.
+// Callers must hold p.mu.
+func (p *Provider) deliver(result transcription.TranscriptResult) {
+	if p.resClosed {
+		return
+	}
+
+	// Reorder: Ein Partial wird zurückgehalten und nach dem folgenden Ergebnis gesendet
+	if p.faults.Reorder && result.IsPartial && p.held == nil {
+		p.held = &result
+		return
+	}
+	p.enqueue(result)
+	if p.held != nil {
+		p.enqueue(*p.held)
+		p.held = nil
+	}
+}
+
+// enqueue zählt das Ergebnis und wendet Latenzspitzen und Bursts an. Callers must hold p.mu.
+func (p *Provider) enqueue(result transcription.TranscriptResult) {
+	p.delivered++
+
+	if p.outbox == nil {
+		// Non-blocking select um Deadlocks zu vermeiden
+		select {
+		case p.resChan <- result:
+		default:
+			// Wenn der Channel voll ist, droppen wir das Update (Mock-Verhalten)
+		}
+		return
+	}
+
+	item := outgoing{result: result}
+	if p.faults.SpikeEvery > 0 && p.delivered%p.faults.SpikeEvery == 0 {
+		item.delay = p.faults.SpikeDelay
+	}
+	copies := 1
+	if p.faults.FloodEvery > 0 && p.delivered%p.faults.FloodEvery == 0 {
+		copies = p.faults.FloodSize
+	}
+	for i := 0; i < copies; i++ {
+		select {
+		case p.outbox <- item:
+		default:
+		}
+	}
+}
+
+// deliverLoop sendet die Ergebnisse der outbox mit Verzögerung und blockierend,
+// damit Bursts den Empfänger tatsächlich fluten.
+func (p *Provider) deliverLoop() {
+	defer p.wg.Done()
+	defer close(p.resChan)
+
+	for item := range p.outbox {
+		if item.delay > 0 {
+			select {
+			case <-time.After(item.delay):
+			case <-p.done:
+				return
+			}
+		}
+		select {
+		case p.resChan <- item.result:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// closeResults schliesst den Result-Channel; bei asynchroner Auslieferung erst nach
+// den ausstehenden Ergebnissen. Callers must hold p.mu.
+func (p *Provider) closeResults() {
+	p.resClosed = true
+	if p.outbox != nil {
+		close(p.outbox)
+	} else {
+		close(p.resChan)
+	}
+}
+
+func (p *Provider) reportActivity(event transcription.SpeechEvent) {
 	select {
-	case p.resChan <- result:
+	case p.activity <- event:
 	default:
-		// Wenn der Channel voll ist, droppen wir das Update (Mock-Verhalten)
 	}
+}
+
+// UpdateVocabulary merkt sich das neue Vokabular. Es wird nur geloggt, ein Resultat
+// würde in History, Store und Exporte gelangen; der Raum sendet selbst "vocabulary_update".
+func (p *Provider) UpdateVocabulary(phrases []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
+	p.echoVocabulary(phrases)
 	return nil
 }
 
+func (p *Provider) echoVocabulary(phrases []string) {
+	p.vocabulary = phrases
+	log.Printf("Mock: Vokabular: %s", strings.Join(phrases, ", "))
+}
+
+// Vocabulary liefert das zuletzt gesetzte Vokabular
+func (p *Provider) Vocabulary() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.vocabulary
+}
+
 func (p *Provider) ResultChan() <-chan transcription.TranscriptResult {
 	return p.resChan
 }
@@ -126,10 +400,26 @@ func (p *Provider) ErrorChan() <-chan error {
 	return p.errChan
 }
 
+func (p *Provider) ActivityChan() <-chan transcription.SpeechEvent {
+	return p.activity
+}
+
 func (p *Provider) Close() error {
-	log.Println("Mock: Closed")
-	close(p.resChan)
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.isClosed = true
+	close(p.done)
+	if !p.resClosed {
+		p.closeResults()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
 	close(p.errChan)
+	log.Println("Mock: Closed")
 	return nil
 }
 
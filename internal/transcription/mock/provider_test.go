@@ -1,6 +1,7 @@
 package mock_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -101,3 +102,108 @@ func TestProvider_Close(t *testing.T) {
 		t.Fatal("Timeout: ErrorChan was not closed properly")
 	}
 }
+
+func TestProvider_WordTimings(t *testing.T) {
+	provider := mock.New()
+	dummyData := []byte("fake-audio-data")
+
+	// Vier Pakete ergeben zwei Wörter ("Hallo zusammen,")
+	for i := 0; i < 4; i++ {
+		_ = provider.SendAudio(dummyData)
+	}
+
+	var last transcription.TranscriptResult
+	for i := 0; i < 2; i++ {
+		select {
+		case last = <-provider.ResultChan():
+		case <-time.After(1 * time.Second):
+			t.Fatal("Timeout waiting for result from mock provider")
+		}
+	}
+
+	if len(last.Words) != 2 {
+		t.Fatalf("Expected 2 words, got %d", len(last.Words))
+	}
+	if last.Words[0].Text != "Hallo" || last.Words[1].Text != "zusammen," {
+		t.Errorf("Unexpected words: %+v", last.Words)
+	}
+	if last.Words[1].Start != last.Words[0].End {
+		t.Errorf("Expected words to be contiguous, got %+v", last.Words)
+	}
+	if last.Start != 0 || last.End != 1.0 {
+		t.Errorf("Expected utterance 0s-1s, got %.2fs-%.2fs", last.Start, last.End)
+	}
+	if last.Words[0].Speaker != "Speaker 1" {
+		t.Errorf("Expected word speaker 'Speaker 1', got '%s'", last.Words[0].Speaker)
+	}
+}
+
+func TestProvider_CodeSwitching(t *testing.T) {
+	provider := mock.New()
+	if err := provider.Connect(context.Background(), transcription.Options{Language: "de-CH", AutoDetect: true}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	// Erstes Wort des gemischtsprachigen Skripts
+	for i := 0; i < 2; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+
+	select {
+	case result := <-provider.ResultChan():
+		if result.Text != "Grüezi" {
+			t.Errorf("Expected text 'Grüezi', got '%s'", result.Text)
+		}
+		if result.Language != "de-CH" {
+			t.Errorf("Expected language 'de-CH', got '%s'", result.Language)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("Timeout waiting for result from mock provider")
+	}
+}
+
+// Compile-time check: Der Mock unterstützt Live-Vokabular
+var _ transcription.VocabularyUpdater = (*mock.Provider)(nil)
+
+func TestProvider_UpdateVocabulary(t *testing.T) {
+	provider := mock.New()
+
+	if err := provider.UpdateVocabulary([]string{"Tolka", "Zürich"}); err != nil {
+		t.Fatalf("UpdateVocabulary failed: %v", err)
+	}
+
+	// Das Vokabular ist kein Transkript
+	select {
+	case result := <-provider.ResultChan():
+		t.Errorf("Expected no result for a vocabulary update, got %+v", result)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if got := provider.Vocabulary(); len(got) != 2 {
+		t.Errorf("Expected 2 phrases, got %v", got)
+	}
+}
+
+func TestProvider_SpeechActivity(t *testing.T) {
+	provider := mock.New()
+
+	// Der erste Satz hat acht Wörter, also 16 Pakete
+	for i := 0; i < 16; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+
+	expected := []transcription.SpeechEvent{
+		{Type: transcription.SpeechStart, Speaker: "Speaker 1", Time: 0},
+		{Type: transcription.SpeechEnd, Speaker: "Speaker 1", Time: 4.0},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-provider.ActivityChan():
+			if got != want {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatalf("Timeout waiting for %s", want.Type)
+		}
+	}
+}
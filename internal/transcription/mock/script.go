@@ -0,0 +1,184 @@
+package mock
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Partial-Verhalten einer Skriptzeile
+const (
+	PartialsWords = "words" // Ein Partial pro Wort (Standard)
+	PartialsNone  = "none"  // Nur das Final
+)
+
+// defaultWordsPerSecond entspricht einem Wort alle zwei Audio-Pakete
+const defaultWordsPerSecond = 2
+
+// Eingebaute Skripte, per Name wählbar
+var builtinScripts = map[string][]ScriptLine{
+	"conversation":   conversationScript,
+	"code-switching": codeSwitchingScript,
+}
+
+// scriptExtensions in der Reihenfolge, in der FindScript sucht
+var scriptExtensions = []string{".yaml", ".yml", ".json", ".srt"}
+
+// FindScript liefert ein eingebautes Skript oder lädt <dir>/<name>.{yaml,yml,json,srt}.
+// Namen mit Pfadanteilen werden abgelehnt.
+func FindScript(dir, name string) ([]ScriptLine, error) {
+	if script, ok := builtinScripts[name]; ok {
+		return script, nil
+	}
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid script name %q", name)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("script %q not found (no script directory configured)", name)
+	}
+
+	for _, ext := range scriptExtensions {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return LoadScript(path)
+		}
+	}
+	return nil, fmt.Errorf("script %q not found in %s", name, dir)
+}
+
+// LoadScript liest ein Skript aus einer JSON-, YAML- oder SRT-Datei.
+// JSON und YAML enthalten eine Liste von Zeilen oder ein Objekt mit "lines".
+func LoadScript(path string) ([]ScriptLine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var script []ScriptLine
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		script, err = parseStructured(data, json.Unmarshal)
+	case ".yaml", ".yml":
+		script, err = parseStructured(data, yaml.Unmarshal)
+	case ".srt":
+		script, err = ParseSRT(string(data))
+	default:
+		return nil, fmt.Errorf("unsupported script format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if err := ValidateScript(script); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return script, nil
+}
+
+func parseStructured(data []byte, unmarshal func([]byte, interface{}) error) ([]ScriptLine, error) {
+	var lines []ScriptLine
+	if err := unmarshal(data, &lines); err == nil {
+		return lines, nil
+	}
+
+	var doc struct {
+		Lines []ScriptLine `json:"lines" yaml:"lines"`
+	}
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Lines, nil
+}
+
+// ValidateScript prüft Texte, Tempo, Pausen und Partial-Verhalten aller Zeilen.
+func ValidateScript(script []ScriptLine) error {
+	if len(script) == 0 {
+		return errors.New("script has no lines")
+	}
+	for i, line := range script {
+		switch {
+		case strings.TrimSpace(line.Text) == "":
+			return fmt.Errorf("line %d: text is empty", i+1)
+		case line.WordsPerSecond < 0:
+			return fmt.Errorf("line %d: words_per_second must not be negative", i+1)
+		case line.Pause < 0:
+			return fmt.Errorf("line %d: pause must not be negative", i+1)
+		case line.Partials != "" && line.Partials != PartialsWords && line.Partials != PartialsNone:
+			return fmt.Errorf("line %d: partials must be %q or %q", i+1, PartialsWords, PartialsNone)
+		}
+	}
+	return nil
+}
+
+// srtTiming matches "00:00:01,000 --> 00:00:04,500"
+var srtTiming = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})[,.](\d{3})\s*-->\s*(\d+):(\d{2}):(\d{2})[,.](\d{3})`)
+
+// ParseSRT wandelt Untertitel in Skriptzeilen. Tempo und Pausen ergeben sich aus den
+// Zeitstempeln; ein Präfix "Name: " im Text wird zum Sprecher.
+func ParseSRT(content string) ([]ScriptLine, error) {
+	var (
+		script  []ScriptLine
+		lastEnd float64
+		start   float64
+		end     float64
+		text    []string
+		inCue   bool
+	)
+
+	flush := func() {
+		if inCue && len(text) > 0 {
+			script = append(script, srtLine(strings.Join(text, " "), start, end, lastEnd))
+			lastEnd = end
+		}
+		inCue, text = false, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(content, "\r\n", "\n")))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+			flush()
+		case !inCue:
+			if m := srtTiming.FindStringSubmatch(line); m != nil {
+				start, end = srtSeconds(m[1:5]), srtSeconds(m[5:9])
+				if end <= start {
+					return nil, fmt.Errorf("line %d: cue ends before it starts", lineNo)
+				}
+				inCue = true
+			}
+			// Sonst die Cue-Nummer, die wir nicht brauchen
+		default:
+			text = append(text, line)
+		}
+	}
+	flush()
+	return script, scanner.Err()
+}
+
+func srtLine(text string, start, end, lastEnd float64) ScriptLine {
+	line := ScriptLine{Speaker: "Speaker 1", Text: text}
+	// "Anna: ..." oder "Speaker 2: ..."
+	if name, rest, ok := strings.Cut(text, ": "); ok && len(strings.Fields(name)) <= 2 {
+		line.Speaker, line.Text = name, rest
+	}
+	// Überlappende Cues sind in Untertiteln häufig, der Mock spricht sie nacheinander
+	line.Pause = max(start-lastEnd, 0)
+	line.WordsPerSecond = float64(len(strings.Fields(line.Text))) / (end - start)
+	return line
+}
+
+func srtSeconds(parts []string) float64 {
+	var v [4]int
+	for i, p := range parts {
+		v[i], _ = strconv.Atoi(p)
+	}
+	return float64(v[0]*3600+v[1]*60+v[2]) + float64(v[3])/1000
+}
@@ -0,0 +1,121 @@
+package mock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/mock"
+)
+
+func TestLoadScript_Formats(t *testing.T) {
+	yamlScript, err := mock.FindScript("testdata", "standup")
+	if err != nil {
+		t.Fatalf("Loading YAML failed: %v", err)
+	}
+	if len(yamlScript) != 2 || yamlScript[0].WordsPerSecond != 4 || yamlScript[1].Pause != 1.5 || yamlScript[1].Partials != mock.PartialsNone {
+		t.Errorf("Unexpected YAML script: %+v", yamlScript)
+	}
+
+	jsonScript, err := mock.FindScript("testdata", "interview")
+	if err != nil {
+		t.Fatalf("Loading JSON failed: %v", err)
+	}
+	if len(jsonScript) != 2 || jsonScript[1].Speaker != "Guest" || jsonScript[1].Language != "en-US" {
+		t.Errorf("Unexpected JSON script: %+v", jsonScript)
+	}
+
+	srtScript, err := mock.FindScript("testdata", "briefing")
+	if err != nil {
+		t.Fatalf("Loading SRT failed: %v", err)
+	}
+	expected := []mock.ScriptLine{
+		{Speaker: "Anna", Text: "Hallo zusammen", Pause: 1, WordsPerSecond: 1},
+		{Speaker: "Speaker 1", Text: "Wie geht es euch allen?", Pause: 1, WordsPerSecond: 5},
+	}
+	if len(srtScript) != len(expected) {
+		t.Fatalf("Expected %d SRT lines, got %+v", len(expected), srtScript)
+	}
+	for i, want := range expected {
+		if srtScript[i] != want {
+			t.Errorf("SRT line %d: expected %+v, got %+v", i, want, srtScript[i])
+		}
+	}
+}
+
+func TestParseSRT_OverlappingCues(t *testing.T) {
+	script, err := mock.ParseSRT(`1
+00:00:01,000 --> 00:00:03,000
+Anna: Hallo zusammen
+
+2
+00:00:02,500 --> 00:00:04,500
+Ben: Hallo Anna
+`)
+	if err != nil {
+		t.Fatalf("ParseSRT failed: %v", err)
+	}
+	if len(script) != 2 || script[1].Speaker != "Ben" || script[1].Pause != 0 {
+		t.Errorf("Expected overlapping cue without pause, got %+v", script)
+	}
+	if err := mock.ValidateScript(script); err != nil {
+		t.Errorf("Expected overlapping cues to be valid, got %v", err)
+	}
+}
+
+func TestFindScript_Invalid(t *testing.T) {
+	for _, name := range []string{"../testdata/standup", "missing", ".hidden", ""} {
+		if _, err := mock.FindScript("testdata", name); err == nil {
+			t.Errorf("Expected error for script %q", name)
+		}
+	}
+
+	if script, err := mock.FindScript("", "code-switching"); err != nil || len(script) == 0 {
+		t.Errorf("Expected built-in script, got %v", err)
+	}
+
+	invalid := [][]mock.ScriptLine{
+		nil,
+		{{Speaker: "A", Text: " "}},
+		{{Speaker: "A", Text: "Hallo", WordsPerSecond: -1}},
+		{{Speaker: "A", Text: "Hallo", Partials: "chunks"}},
+	}
+	for _, script := range invalid {
+		if err := mock.ValidateScript(script); err == nil {
+			t.Errorf("Expected validation error for %+v", script)
+		}
+	}
+}
+
+func TestProvider_ScriptPacing(t *testing.T) {
+	script, err := mock.FindScript("testdata", "standup")
+	if err != nil {
+		t.Fatalf("Loading script failed: %v", err)
+	}
+	provider := mock.NewScripted(script)
+
+	// Anna: drei Wörter mit 4 Wörtern/s = 0.75s, Ben: 1.5s Pause, zwei Wörter = 1s
+	for i := 0; i < 13; i++ {
+		_ = provider.SendAudio([]byte("fake-audio-data"))
+	}
+
+	var results []transcription.TranscriptResult
+	for len(results) < 4 {
+		select {
+		case res := <-provider.ResultChan():
+			results = append(results, res)
+		case <-time.After(1 * time.Second):
+			t.Fatalf("Timeout after %d results", len(results))
+		}
+	}
+
+	anna := results[2]
+	if anna.IsPartial || anna.Speaker != "Anna" || anna.End != 0.75 {
+		t.Errorf("Expected Anna's final at 0.75s, got %+v", anna)
+	}
+	// Ben sendet keine Partials, nur das Final nach Pause und zwei Wörtern
+	ben := results[3]
+	if ben.IsPartial || ben.Speaker != "Ben" || ben.Start != 2.25 || ben.End != 3.25 {
+		t.Errorf("Expected Ben's final from 2.25s to 3.25s, got %+v", ben)
+	}
+}
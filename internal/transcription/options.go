@@ -0,0 +1,123 @@
+package transcription
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when a session does not request a language.
+const DefaultLanguage = "de-CH"
+
+// ErrUnsupportedLanguage is returned when a provider cannot handle a requested language.
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// Options configure a single transcription session.
+type Options struct {
+	// Language is the BCP-47 code of the spoken language, e.g. "de-CH".
+	Language string `json:"language"`
+	// Languages optionally lists further candidate languages of the session.
+	Languages []string `json:"languages,omitempty"`
+	// AutoDetect enables continuous language identification across all candidates.
+	// Results are then tagged with the detected language.
+	AutoDetect bool `json:"auto_detect,omitempty"`
+	// Vocabulary lists names and terms the provider should prefer.
+	Vocabulary []string `json:"vocabulary,omitempty"`
+	// ProviderOptions holds provider specific settings keyed by provider name,
+	// e.g. {"deepgram": {"endpointing": "300"}}.
+	ProviderOptions map[string]ProviderParams `json:"provider_options,omitempty"`
+	// AudioConsent confirms that everyone in the session agreed to have their voice recorded.
+	// The server stores the host's audio only with it. Providers ignore it.
+	AudioConsent bool `json:"audio_consent,omitempty"`
+	// Record writes audio and results of the session to disk for replay, see package replay.
+	// Requires AudioConsent. Providers ignore it.
+	Record bool `json:"record,omitempty"`
+}
+
+// ProviderParams are the settings of a single provider. Values are kept as strings;
+// JSON numbers and booleans are accepted as well.
+type ProviderParams map[string]string
+
+func (p *ProviderParams) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	params := make(ProviderParams, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			params[key] = v
+		case float64:
+			params[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			params[key] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("option %q must be a string, number or boolean", key)
+		}
+	}
+	*p = params
+	return nil
+}
+
+// Candidates returns Language followed by Languages without duplicates.
+func (o Options) Candidates() []string {
+	var out []string
+	for _, lang := range append([]string{o.Language}, o.Languages...) {
+		if lang != "" && !containsFold(out, lang) {
+			out = append(out, lang)
+		}
+	}
+	return out
+}
+
+// WithDefaults returns a copy with empty fields set to their defaults.
+func (o Options) WithDefaults() Options {
+	if o.Language == "" {
+		o.Language = DefaultLanguage
+	}
+	return o
+}
+
+// Validate checks all requested languages against the list a provider supports.
+// The comparison is case-insensitive. An empty list accepts every language.
+func (o Options) Validate(supported []string) error {
+	if len(supported) == 0 {
+		return nil
+	}
+
+	for _, lang := range o.Candidates() {
+		if !containsFold(supported, lang) {
+			return fmt.Errorf("%w %q (supported: %s)", ErrUnsupportedLanguage, lang, strings.Join(supported, ", "))
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeParams returns defaults overridden by params. Keys missing in defaults are rejected,
+// so only settings a provider declares can be changed per session.
+func MergeParams(defaults map[string]string, params ProviderParams) (ProviderParams, error) {
+	merged := make(ProviderParams, len(defaults))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range params {
+		if _, ok := defaults[key]; !ok {
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+		merged[key] = value
+	}
+	return merged, nil
+}
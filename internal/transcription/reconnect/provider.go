@@ -0,0 +1,353 @@
+// Package reconnect wraps a transcription.Service and reconnects it after failures.
+// Audio sent during the outage is buffered and replayed once the new connection is up.
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Factory creates a fresh, unconnected service for every connection attempt.
+type Factory func() (transcription.Service, error)
+
+// Config controls backoff and buffering.
+type Config struct {
+	Name           string
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxDowntime gives up reconnecting after this long. The failure is then reported on ErrorChan.
+	MaxDowntime time.Duration
+	// MaxBuffer is the number of audio bytes kept during an outage. Oldest chunks are dropped first.
+	MaxBuffer int
+	// ReplayHeader resends the first audio chunk after reconnecting. Container formats like
+	// WebM carry their header only in the first chunk.
+	ReplayHeader bool
+}
+
+// DefaultConfig keeps about 30 seconds of 16 kHz PCM during an outage of up to two minutes.
+var DefaultConfig = Config{
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	MaxDowntime:    2 * time.Minute,
+	MaxBuffer:      1 << 20,
+}
+
+var errClosed = errors.New("provider is closed")
+
+type Provider struct {
+	cfg     Config
+	factory Factory
+
+	ctx  context.Context
+	opts transcription.Options
+
+	resChan    chan transcription.TranscriptResult
+	errChan    chan error
+	statusChan chan transcription.Status
+	activity   chan transcription.SpeechEvent
+	done       chan struct{}
+	wg         sync.WaitGroup
+
+	mu       sync.Mutex
+	inner    transcription.Service // nil during an outage
+	buffer   [][]byte
+	buffered int
+	header   []byte
+	isClosed bool
+
+	// Stream times restart at zero on every connection. Results of a new connection
+	// are moved behind the last result of the previous ones. Only used by supervise.
+	offset float64
+	end    float64
+}
+
+func New(factory Factory, cfg Config) *Provider {
+	if cfg.Name == "" {
+		cfg.Name = "Reconnect"
+	}
+	return &Provider{
+		cfg:        cfg,
+		factory:    factory,
+		resChan:    make(chan transcription.TranscriptResult, 100),
+		errChan:    make(chan error, 10),
+		statusChan: make(chan transcription.Status, 10),
+		activity:   make(chan transcription.SpeechEvent, 10),
+		done:       make(chan struct{}),
+	}
+}
+
+// Connect establishes the first connection. Failures here are returned directly.
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	inner, err := p.factory()
+	if err != nil {
+		return err
+	}
+	if err := inner.Connect(ctx, opts); err != nil {
+		inner.Close()
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed {
+		inner.Close()
+		return errClosed
+	}
+	p.ctx, p.opts, p.inner = ctx, opts, inner
+
+	p.wg.Add(1)
+	go p.supervise(inner)
+	return nil
+}
+
+// supervise forwards results and replaces the inner service whenever it fails.
+func (p *Provider) supervise(inner transcription.Service) {
+	defer p.wg.Done()
+
+	for {
+		failure := p.pump(inner)
+		if failure == nil {
+			return // Closed
+		}
+		if transcription.IsPermanent(failure) {
+			// Invalid credentials or exhausted quota: reconnecting cannot help
+			inner.Close()
+			select {
+			case p.errChan <- failure:
+			default:
+			}
+			return
+		}
+
+		// Close first: a SendAudio blocked on the dead connection returns and releases p.mu
+		inner.Close()
+		p.mu.Lock()
+		p.inner = nil // SendAudio buffers from now on
+		p.mu.Unlock()
+
+		log.Printf("%s: Connection lost: %v", p.cfg.Name, failure)
+		p.report(transcription.Status{State: transcription.StateReconnecting, Error: failure.Error()})
+
+		next, err := p.reconnect()
+		if err != nil {
+			if !errors.Is(err, errClosed) {
+				select {
+				case p.errChan <- err:
+				default:
+				}
+			}
+			return
+		}
+
+		replayed, err := p.resume(next)
+		if err != nil {
+			log.Printf("%s: Replaying audio failed: %v", p.cfg.Name, err)
+		}
+		log.Printf("%s: Reconnected, replayed %d bytes", p.cfg.Name, replayed)
+		p.report(transcription.Status{State: transcription.StateConnected})
+		inner = next
+		p.offset = p.end
+	}
+}
+
+// pump forwards results until the inner service fails. Returns nil once closed.
+func (p *Provider) pump(inner transcription.Service) error {
+	for {
+		select {
+		case <-p.done:
+			return nil
+		case result, ok := <-inner.ResultChan():
+			if !ok {
+				return errors.New("result channel closed")
+			}
+			result.Shift(p.offset)
+			p.end = max(p.end, result.End)
+			select {
+			case p.resChan <- result:
+			case <-p.done:
+				return nil
+			}
+		case err, ok := <-inner.ErrorChan():
+			if !ok {
+				return errors.New("error channel closed")
+			}
+			return err
+		case event := <-transcription.ActivityChan(inner):
+			if event.Time > 0 {
+				event.Time += p.offset
+			}
+			select {
+			case p.activity <- event:
+			default:
+				// Activity is only a hint, drop it if nobody listens
+			}
+		}
+	}
+}
+
+// reconnect retries with exponential backoff until a new service connects or MaxDowntime is reached.
+func (p *Provider) reconnect() (transcription.Service, error) {
+	delay := p.cfg.InitialBackoff
+	deadline := time.Now().Add(p.cfg.MaxDowntime)
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.done:
+			return nil, errClosed
+		case <-p.ctx.Done():
+			return nil, errClosed
+		case <-time.After(delay):
+		}
+
+		p.mu.Lock()
+		opts, buffered := p.opts, p.buffered
+		p.mu.Unlock()
+		p.report(transcription.Status{State: transcription.StateReconnecting, Attempt: attempt, BufferedBytes: buffered})
+
+		inner, err := p.factory()
+		if err == nil {
+			if err = inner.Connect(p.ctx, opts); err == nil {
+				return inner, nil
+			}
+			inner.Close()
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("%s: reconnect failed after %d attempts: %w", p.cfg.Name, attempt, err)
+		}
+		delay = min(delay*2, p.cfg.MaxBackoff)
+	}
+}
+
+// resume replays the buffered audio to the new service and makes it active.
+func (p *Provider) resume(next transcription.Service) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed {
+		next.Close()
+		return 0, errClosed
+	}
+
+	var err error
+	replayed := 0
+	if p.cfg.ReplayHeader && p.header != nil {
+		err = next.SendAudio(p.header)
+	}
+	for _, chunk := range p.buffer {
+		if err != nil {
+			break
+		}
+		err = next.SendAudio(chunk)
+		replayed += len(chunk)
+	}
+
+	p.buffer, p.buffered = nil, 0
+	p.inner = next
+	return replayed, err
+}
+
+func (p *Provider) report(status transcription.Status) {
+	select {
+	case p.statusChan <- status:
+	default:
+	}
+}
+
+// SendAudio forwards audio to the active service or buffers it during an outage.
+func (p *Provider) SendAudio(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed {
+		return errClosed
+	}
+	if p.header == nil && len(data) > 0 {
+		p.header = append([]byte(nil), data...)
+	}
+
+	if p.inner != nil {
+		if err := p.inner.SendAudio(data); err == nil {
+			return nil
+		}
+		// Connection is probably dying, keep the chunk for the replay
+	}
+
+	p.bufferAudio(data)
+	return nil
+}
+
+// bufferAudio stores a copy of the chunk and drops the oldest ones above MaxBuffer.
+// Callers must hold p.mu.
+func (p *Provider) bufferAudio(data []byte) {
+	chunk := append([]byte(nil), data...)
+	p.buffer = append(p.buffer, chunk)
+	p.buffered += len(chunk)
+
+	for p.buffered > p.cfg.MaxBuffer && len(p.buffer) > 0 {
+		p.buffered -= len(p.buffer[0])
+		p.buffer = p.buffer[1:]
+	}
+}
+
+// UpdateVocabulary forwards the update and keeps it for future reconnects.
+func (p *Provider) UpdateVocabulary(phrases []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.opts.Vocabulary = phrases
+	if updater, ok := p.inner.(transcription.VocabularyUpdater); ok {
+		return updater.UpdateVocabulary(phrases)
+	}
+	return nil
+}
+
+// Flush forwards to the current inner service if it supports flushing.
+func (p *Provider) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if flusher, ok := p.inner.(transcription.Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.isClosed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	p.mu.Lock()
+	inner := p.inner
+	p.inner = nil
+	p.mu.Unlock()
+
+	var err error
+	if inner != nil {
+		err = inner.Close()
+	}
+
+	close(p.resChan)
+	close(p.errChan)
+	return err
+}
+
+func (p *Provider) ResultChan() <-chan transcription.TranscriptResult { return p.resChan }
+func (p *Provider) ErrorChan() <-chan error                           { return p.errChan }
+func (p *Provider) StatusChan() <-chan transcription.Status           { return p.statusChan }
+func (p *Provider) ActivityChan() <-chan transcription.SpeechEvent    { return p.activity }
@@ -0,0 +1,229 @@
+package reconnect_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/reconnect"
+)
+
+// Compile-time checks
+var _ transcription.Service = (*reconnect.Provider)(nil)
+var _ transcription.StatusReporter = (*reconnect.Provider)(nil)
+
+// fakeService records audio and lets the test inject failures
+type fakeService struct {
+	connectErr error
+	resChan    chan transcription.TranscriptResult
+	errChan    chan error
+
+	mu    sync.Mutex
+	audio []string
+}
+
+func newFake(connectErr error) *fakeService {
+	return &fakeService{
+		connectErr: connectErr,
+		resChan:    make(chan transcription.TranscriptResult, 10),
+		errChan:    make(chan error, 1),
+	}
+}
+
+func (f *fakeService) Connect(ctx context.Context, opts transcription.Options) error {
+	return f.connectErr
+}
+func (f *fakeService) SendAudio(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.audio = append(f.audio, string(data))
+	return nil
+}
+func (f *fakeService) ResultChan() <-chan transcription.TranscriptResult { return f.resChan }
+func (f *fakeService) ErrorChan() <-chan error                           { return f.errChan }
+func (f *fakeService) Close() error                                      { return nil }
+
+func (f *fakeService) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.audio...)
+}
+
+var testConfig = reconnect.Config{
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     20 * time.Millisecond,
+	MaxDowntime:    time.Second,
+	MaxBuffer:      1024,
+	ReplayHeader:   true,
+}
+
+func waitStatus(t *testing.T, p *reconnect.Provider, state string) transcription.Status {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case status := <-p.StatusChan():
+			if status.State == state {
+				return status
+			}
+		case <-timeout:
+			t.Fatalf("Timeout waiting for state %s", state)
+		}
+	}
+}
+
+func TestProvider_ReconnectAndReplay(t *testing.T) {
+	first := newFake(nil)
+	second := newFake(nil)
+	services := []*fakeService{first, newFake(errors.New("still down")), second}
+
+	var mu sync.Mutex
+	provider := reconnect.New(func() (transcription.Service, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		next := services[0]
+		services = services[1:]
+		return next, nil
+	}, testConfig)
+
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	provider.SendAudio([]byte("header"))
+	first.errChan <- errors.New("network blip")
+	waitStatus(t, provider, transcription.StateReconnecting)
+
+	// Während des Ausfalls gepuffert
+	provider.SendAudio([]byte("gap"))
+
+	waitStatus(t, provider, transcription.StateConnected)
+
+	got := second.received()
+	if len(got) != 2 || got[0] != "header" || got[1] != "gap" {
+		t.Errorf("Expected replay [header gap], got %v", got)
+	}
+
+	second.resChan <- transcription.TranscriptResult{Text: "wieder da"}
+	select {
+	case result := <-provider.ResultChan():
+		if result.Text != "wieder da" {
+			t.Errorf("Expected result from new connection, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for result after reconnect")
+	}
+}
+
+func TestProvider_ReconnectShiftsTimings(t *testing.T) {
+	first := newFake(nil)
+	second := newFake(nil)
+	services := []*fakeService{first, second}
+
+	var mu sync.Mutex
+	provider := reconnect.New(func() (transcription.Service, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		next := services[0]
+		services = services[1:]
+		return next, nil
+	}, testConfig)
+
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	next := func() transcription.TranscriptResult {
+		t.Helper()
+		select {
+		case result := <-provider.ResultChan():
+			return result
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for result")
+			return transcription.TranscriptResult{}
+		}
+	}
+
+	first.resChan <- transcription.TranscriptResult{Text: "Hallo", Start: 0.5, End: 4}
+	if result := next(); result.Start != 0.5 || result.End != 4 {
+		t.Errorf("Expected unchanged timings before the reconnect, got %+v", result)
+	}
+
+	// Mitten im Transkript: die neue Verbindung zählt wieder ab null
+	first.errChan <- errors.New("network blip")
+	waitStatus(t, provider, transcription.StateConnected)
+
+	words := []transcription.Word{{Text: "wieder", Start: 1, End: 1.5}, {Text: "da", Start: 1.5, End: 2}}
+	second.resChan <- transcription.TranscriptResult{Text: "wieder da", Start: 1, End: 2, Words: words}
+	result := next()
+	if result.Start != 5 || result.End != 6 || result.Words[1].Start != 5.5 {
+		t.Errorf("Expected timings after the first connection, got %+v", result)
+	}
+	if words[0].Start != 1 {
+		t.Error("Expected the provider's words to stay unchanged")
+	}
+}
+
+func TestProvider_GivesUp(t *testing.T) {
+	first := newFake(nil)
+	calls := 0
+	cfg := testConfig
+	cfg.MaxDowntime = 50 * time.Millisecond
+
+	provider := reconnect.New(func() (transcription.Service, error) {
+		calls++
+		if calls == 1 {
+			return first, nil
+		}
+		return nil, errors.New("auth failed")
+	}, cfg)
+
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	first.errChan <- errors.New("connection reset")
+
+	select {
+	case err := <-provider.ErrorChan():
+		if err == nil {
+			t.Error("Expected error after giving up")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for final error")
+	}
+}
+
+func TestProvider_PermanentErrorNoRetry(t *testing.T) {
+	first := newFake(nil)
+	calls := 0
+	provider := reconnect.New(func() (transcription.Service, error) {
+		calls++
+		return first, nil
+	}, testConfig)
+
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	first.errChan <- &transcription.ProviderError{Provider: "Azure", Kind: transcription.ErrQuota}
+
+	select {
+	case err := <-provider.ErrorChan():
+		if !errors.Is(err, transcription.ErrQuota) {
+			t.Errorf("Expected ErrQuota, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for quota error")
+	}
+	if calls != 1 {
+		t.Errorf("Expected no reconnect attempts, got %d factory calls", calls)
+	}
+}
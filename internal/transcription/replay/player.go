@@ -0,0 +1,230 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// MaxSpeed limits how much faster than real time a recording is played.
+const MaxSpeed = 10.0
+
+// Capabilities of the replay provider. The recorded provider decides what is
+// actually in the results; audio from the host is ignored.
+var Capabilities = transcription.Capabilities{
+	Diarization: true,
+	Partials:    true,
+	WordTimings: true,
+	AutoDetect:  true,
+}
+
+func validateSpeed(speed float64) error {
+	if speed <= 0 || speed > MaxSpeed {
+		return fmt.Errorf("replay speed must be greater than 0 and at most %g", MaxSpeed)
+	}
+	return nil
+}
+
+// waitUntil sleeps until offset seconds (scaled by speed) after start.
+// Returns false if done is closed first.
+func waitUntil(start time.Time, offset, speed float64, done <-chan struct{}) bool {
+	due := start.Add(time.Duration(offset / speed * float64(time.Second)))
+	select {
+	case <-time.After(time.Until(due)):
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Provider re-emits the recorded results, errors and speech activity with their
+// original timing. Incoming audio is ignored. After the last entry the provider
+// stays connected and silent, like a provider without speech.
+type Provider struct {
+	rec   *Recording
+	speed float64
+
+	resChan   chan transcription.TranscriptResult
+	errChan   chan error
+	activity  chan transcription.SpeechEvent
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New creates a replay of rec. speed scales the timing, 2 plays twice as fast.
+func New(rec *Recording, speed float64) (*Provider, error) {
+	if err := validateSpeed(speed); err != nil {
+		return nil, err
+	}
+	return &Provider{
+		rec:      rec,
+		speed:    speed,
+		resChan:  make(chan transcription.TranscriptResult, 100),
+		errChan:  make(chan error, 10),
+		activity: make(chan transcription.SpeechEvent, 10),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	log.Printf("Replay: Playing %s recording (%.1fs, speed %g)", p.rec.Provider, p.rec.Duration(), p.speed)
+	p.wg.Add(1)
+	go p.play(ctx)
+	return nil
+}
+
+func (p *Provider) play(ctx context.Context) {
+	defer p.wg.Done()
+
+	start := time.Now()
+	for _, entry := range p.rec.Entries {
+		if !waitUntil(start, entry.Time, p.speed, p.done) || ctx.Err() != nil {
+			return
+		}
+
+		switch entry.Type {
+		case EntryResult:
+			if entry.Result == nil {
+				continue
+			}
+			select {
+			case p.resChan <- *entry.Result:
+			case <-p.done:
+				return
+			}
+		case EntryError:
+			select {
+			case p.errChan <- replayedError(entry):
+			case <-p.done:
+				return
+			}
+		case EntryActivity:
+			if entry.Activity == nil {
+				continue
+			}
+			select {
+			case p.activity <- *entry.Activity:
+			default:
+			}
+		}
+	}
+	log.Printf("Replay: End of %s recording", p.rec.Provider)
+}
+
+// SendAudio ignores the host's audio, the results come from the recording.
+func (p *Provider) SendAudio(data []byte) error { return nil }
+
+func (p *Provider) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+		close(p.resChan)
+		close(p.errChan)
+	})
+	return nil
+}
+
+func (p *Provider) ResultChan() <-chan transcription.TranscriptResult { return p.resChan }
+func (p *Provider) ErrorChan() <-chan error                           { return p.errChan }
+func (p *Provider) ActivityChan() <-chan transcription.SpeechEvent    { return p.activity }
+
+// Streamer feeds the recorded audio into another service with the original timing,
+// e.g. to compare providers on identical input. Results come from that service;
+// audio from the host is ignored.
+type Streamer struct {
+	rec   *Recording
+	inner transcription.Service
+	speed float64
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Stream creates a replay of the audio of rec into inner. speed scales the timing.
+func Stream(rec *Recording, inner transcription.Service, speed float64) (*Streamer, error) {
+	if err := validateSpeed(speed); err != nil {
+		return nil, err
+	}
+	return &Streamer{
+		rec:   rec,
+		inner: inner,
+		speed: speed,
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func (s *Streamer) Connect(ctx context.Context, opts transcription.Options) error {
+	if err := s.inner.Connect(ctx, opts); err != nil {
+		return err
+	}
+	log.Printf("Replay: Streaming audio of %s recording (%.1fs, speed %g)", s.rec.Provider, s.rec.Duration(), s.speed)
+	s.wg.Add(1)
+	go s.feed(ctx)
+	return nil
+}
+
+func (s *Streamer) feed(ctx context.Context) {
+	defer s.wg.Done()
+
+	start := time.Now()
+	for _, entry := range s.rec.Entries {
+		if entry.Type != EntryAudio {
+			continue
+		}
+		if !waitUntil(start, entry.Time, s.speed, s.done) || ctx.Err() != nil {
+			return
+		}
+		if err := s.inner.SendAudio(entry.Audio); err != nil {
+			log.Printf("Replay: SendAudio error: %v", err)
+		}
+	}
+
+	// Like a host that stops streaming: the last utterance should not wait for more audio
+	if flusher, ok := s.inner.(transcription.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			log.Printf("Replay: Flush failed: %v", err)
+		}
+	}
+	log.Printf("Replay: End of %s recording", s.rec.Provider)
+}
+
+// SendAudio ignores the host's audio, the recorded audio is streamed instead.
+func (s *Streamer) SendAudio(data []byte) error { return nil }
+
+// UpdateVocabulary forwards to the target service if it supports live updates.
+func (s *Streamer) UpdateVocabulary(phrases []string) error {
+	if updater, ok := s.inner.(transcription.VocabularyUpdater); ok {
+		return updater.UpdateVocabulary(phrases)
+	}
+	return nil
+}
+
+func (s *Streamer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		err = s.inner.Close()
+	})
+	return err
+}
+
+func (s *Streamer) ResultChan() <-chan transcription.TranscriptResult { return s.inner.ResultChan() }
+func (s *Streamer) ErrorChan() <-chan error                           { return s.inner.ErrorChan() }
+func (s *Streamer) ActivityChan() <-chan transcription.SpeechEvent {
+	return transcription.ActivityChan(s.inner)
+}
+
+// StatusChan passes on reconnect states of the target service, nil if it reports none.
+func (s *Streamer) StatusChan() <-chan transcription.Status {
+	if reporter, ok := s.inner.(transcription.StatusReporter); ok {
+		return reporter.StatusChan()
+	}
+	return nil
+}
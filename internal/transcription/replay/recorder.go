@@ -0,0 +1,194 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Recorder wraps a service and writes its audio, results, errors and speech
+// activity to a recording. The wrapped service is used unchanged.
+type Recorder struct {
+	inner  transcription.Service
+	header Entry
+
+	mu     sync.Mutex // Guards out, start and failed
+	out    io.WriteCloser
+	enc    *json.Encoder
+	start  time.Time
+	failed bool
+
+	resChan   chan transcription.TranscriptResult
+	errChan   chan error
+	activity  chan transcription.SpeechEvent
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	// Set by pump once the inner channels are closed
+	resClosed bool
+	errClosed bool
+}
+
+// Record wraps inner. The recording is written to out, which is closed with the service.
+func Record(inner transcription.Service, out io.WriteCloser, provider string, format transcription.AudioFormat) *Recorder {
+	return &Recorder{
+		inner:    inner,
+		header:   Entry{Type: EntrySession, Provider: provider, Format: format},
+		out:      out,
+		enc:      json.NewEncoder(out),
+		resChan:  make(chan transcription.TranscriptResult, 100),
+		errChan:  make(chan error, 10),
+		activity: make(chan transcription.SpeechEvent, 10),
+		done:     make(chan struct{}),
+	}
+}
+
+// Connect starts the recording clock and connects the wrapped service.
+func (r *Recorder) Connect(ctx context.Context, opts transcription.Options) error {
+	r.mu.Lock()
+	r.start = time.Now()
+	r.header.Options = &opts
+	r.writeLocked(r.header)
+	r.mu.Unlock()
+
+	if err := r.inner.Connect(ctx, opts); err != nil {
+		return err
+	}
+
+	r.wg.Add(1)
+	go r.pump()
+	return nil
+}
+
+// pump records and forwards everything the wrapped service reports.
+// Closed channels are passed on, so the room notices failures as before.
+func (r *Recorder) pump() {
+	defer r.wg.Done()
+
+	results, errs := r.inner.ResultChan(), r.inner.ErrorChan()
+	activity := transcription.ActivityChan(r.inner)
+	for results != nil || errs != nil {
+		select {
+		case <-r.done:
+			return
+		case result, ok := <-results:
+			if !ok {
+				close(r.resChan)
+				r.resClosed, results = true, nil
+				continue
+			}
+			r.write(Entry{Type: EntryResult, Result: &result})
+			select {
+			case r.resChan <- result:
+			case <-r.done:
+				return
+			}
+		case err, ok := <-errs:
+			if !ok {
+				close(r.errChan)
+				r.errClosed, errs = true, nil
+				continue
+			}
+			r.write(Entry{Type: EntryError, Error: err.Error(), ErrorKind: errorKind(err)})
+			select {
+			case r.errChan <- err:
+			case <-r.done:
+				return
+			}
+		case event := <-activity:
+			r.write(Entry{Type: EntryActivity, Activity: &event})
+			select {
+			case r.activity <- event:
+			default:
+			}
+		}
+	}
+}
+
+// write appends an entry with the current offset.
+func (r *Recorder) write(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeLocked(entry)
+}
+
+// writeLocked appends an entry. Write errors stop the recording, not the session.
+// Callers must hold r.mu.
+func (r *Recorder) writeLocked(entry Entry) {
+	if r.failed {
+		return
+	}
+	if !r.start.IsZero() && entry.Type != EntrySession {
+		entry.Time = time.Since(r.start).Seconds()
+	}
+	if err := r.enc.Encode(entry); err != nil {
+		log.Printf("Replay: Recording stopped: %v", err)
+		r.failed = true
+	}
+}
+
+// SendAudio records the chunk and forwards it.
+func (r *Recorder) SendAudio(data []byte) error {
+	r.write(Entry{Type: EntryAudio, Audio: data})
+	return r.inner.SendAudio(data)
+}
+
+// UpdateVocabulary forwards to the wrapped service if it supports live updates.
+func (r *Recorder) UpdateVocabulary(phrases []string) error {
+	if updater, ok := r.inner.(transcription.VocabularyUpdater); ok {
+		return updater.UpdateVocabulary(phrases)
+	}
+	return nil
+}
+
+// Flush forwards to the wrapped service if it supports flushing.
+func (r *Recorder) Flush() error {
+	if flusher, ok := r.inner.(transcription.Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Close closes the wrapped service and the recording file.
+func (r *Recorder) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		err = r.inner.Close()
+		r.wg.Wait()
+
+		// pump has exited, its flags are safe to read
+		if !r.resClosed {
+			close(r.resChan)
+		}
+		if !r.errClosed {
+			close(r.errChan)
+		}
+
+		r.mu.Lock()
+		if cerr := r.out.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		r.failed = true // Nothing is written after Close
+		r.mu.Unlock()
+	})
+	return err
+}
+
+func (r *Recorder) ResultChan() <-chan transcription.TranscriptResult { return r.resChan }
+func (r *Recorder) ErrorChan() <-chan error                           { return r.errChan }
+func (r *Recorder) ActivityChan() <-chan transcription.SpeechEvent    { return r.activity }
+
+// StatusChan passes on reconnect states of the wrapped service, nil if it reports none.
+func (r *Recorder) StatusChan() <-chan transcription.Status {
+	if reporter, ok := r.inner.(transcription.StatusReporter); ok {
+		return reporter.StatusChan()
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package replay records sessions to disk and plays them back. A recording holds
+// the host's audio and the provider's results with their timing. Played back, it
+// either re-emits the results or streams the audio into another provider.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Entry types of a recording
+const (
+	EntrySession  = "session"
+	EntryAudio    = "audio"
+	EntryResult   = "result"
+	EntryError    = "error"
+	EntryActivity = "activity"
+)
+
+// Extension of recording files
+const Extension = ".jsonl"
+
+// Entry is a line of a recording file (JSON Lines). The first entry describes
+// the session, all others follow in the order they happened.
+type Entry struct {
+	Type string `json:"type"`
+	// Time is the offset in seconds since Connect.
+	Time float64 `json:"t"`
+
+	// Session
+	Provider string                    `json:"provider,omitempty"`
+	Format   transcription.AudioFormat `json:"audio_format,omitempty"`
+	Options  *transcription.Options    `json:"options,omitempty"`
+
+	Audio     []byte                          `json:"audio,omitempty"` // Base64 in JSON
+	Result    *transcription.TranscriptResult `json:"result,omitempty"`
+	Error     string                          `json:"error,omitempty"`
+	ErrorKind string                          `json:"error_kind,omitempty"` // auth, quota, network, service
+	Activity  *transcription.SpeechEvent      `json:"activity,omitempty"`
+}
+
+// Recording is a loaded recording file.
+type Recording struct {
+	Provider string
+	Format   transcription.AudioFormat
+	Options  transcription.Options
+	// Entries are all entries after the session entry.
+	Entries []Entry
+}
+
+// Find loads the recording name from dir. Names must not contain path components.
+func Find(dir, name string) (*Recording, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid recording name %q", name)
+	}
+	if dir == "" {
+		return nil, errors.New("no recording directory configured")
+	}
+	return Load(filepath.Join(dir, strings.TrimSuffix(name, Extension)+Extension))
+}
+
+// Load reads a recording file.
+func Load(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rec := &Recording{}
+	dec := json.NewDecoder(f)
+	for line := 1; ; line++ {
+		var entry Entry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, line, err)
+		}
+
+		if line == 1 {
+			if entry.Type != EntrySession {
+				return nil, fmt.Errorf("%s: first entry must describe the session", path)
+			}
+			rec.Provider, rec.Format = entry.Provider, entry.Format
+			if entry.Options != nil {
+				rec.Options = *entry.Options
+			}
+			continue
+		}
+		rec.Entries = append(rec.Entries, entry)
+	}
+	if rec.Provider == "" && len(rec.Entries) == 0 {
+		return nil, fmt.Errorf("%s: empty recording", path)
+	}
+	return rec, nil
+}
+
+// Duration returns the offset of the last entry in seconds.
+func (r *Recording) Duration() float64 {
+	if len(r.Entries) == 0 {
+		return 0
+	}
+	return r.Entries[len(r.Entries)-1].Time
+}
+
+// errorKind names the failure class of err for the recording.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, transcription.ErrAuth):
+		return "auth"
+	case errors.Is(err, transcription.ErrQuota):
+		return "quota"
+	case errors.Is(err, transcription.ErrNetwork):
+		return "network"
+	case errors.Is(err, transcription.ErrService), errors.Is(err, transcription.ErrWorkerExited):
+		return "service"
+	}
+	return ""
+}
+
+// replayedError rebuilds a recorded error, keeping its failure class.
+func replayedError(entry Entry) error {
+	if entry.ErrorKind == "" {
+		return errors.New(entry.Error)
+	}
+	return &transcription.ProviderError{
+		Provider: "Replay",
+		Kind:     transcription.ErrorKind(entry.ErrorKind),
+		Detail:   entry.Error,
+	}
+}
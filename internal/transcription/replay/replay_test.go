@@ -0,0 +1,168 @@
+package replay_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/mock"
+	"github.com/joshuabeny1999/tolka/internal/transcription/replay"
+)
+
+// Compile-time checks
+var _ transcription.Service = (*replay.Recorder)(nil)
+var _ transcription.Service = (*replay.Provider)(nil)
+var _ transcription.Service = (*replay.Streamer)(nil)
+
+// recordMock records four audio packets of the mock: two words and an injected network error.
+func recordMock(t *testing.T) string {
+	t.Helper()
+
+	inner, err := mock.FromParams("", map[string]string{"error_after": "1"})
+	if err != nil {
+		t.Fatalf("FromParams failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "session-mock"+replay.Extension)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	recorder := replay.Record(inner, f, "mock", transcription.AudioPCM16)
+	if err := recorder.Connect(context.Background(), transcription.Options{Language: "de-CH"}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := recorder.SendAudio([]byte("fake-audio-data")); err != nil {
+			t.Fatalf("SendAudio failed: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-recorder.ResultChan():
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for result")
+		}
+	}
+	select {
+	case <-recorder.ErrorChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for error")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return path
+}
+
+func TestRecorder(t *testing.T) {
+	rec, err := replay.Load(recordMock(t))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if rec.Provider != "mock" || rec.Format != transcription.AudioPCM16 || rec.Options.Language != "de-CH" {
+		t.Errorf("Unexpected session header: %+v", rec)
+	}
+	counts := make(map[string]int)
+	last := 0.0
+	for _, entry := range rec.Entries {
+		counts[entry.Type]++
+		if entry.Time < last {
+			t.Errorf("Entries out of order at %+v", entry)
+		}
+		last = entry.Time
+	}
+	if counts[replay.EntryAudio] != 4 || counts[replay.EntryResult] != 2 || counts[replay.EntryError] != 1 {
+		t.Errorf("Unexpected entries: %v", counts)
+	}
+	if string(rec.Entries[0].Audio) != "fake-audio-data" {
+		t.Errorf("Expected recorded audio, got %q", rec.Entries[0].Audio)
+	}
+}
+
+func TestProvider_Replay(t *testing.T) {
+	dir := filepath.Dir(recordMock(t))
+	rec, err := replay.Find(dir, "session-mock")
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	provider, err := replay.New(rec, replay.MaxSpeed)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer provider.Close()
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	for _, want := range []string{"Hallo", "Hallo zusammen,"} {
+		select {
+		case result := <-provider.ResultChan():
+			if result.Text != want || !result.IsPartial {
+				t.Errorf("Expected partial '%s', got %+v", want, result)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for '%s'", want)
+		}
+	}
+
+	// Die Fehlerklasse bleibt erhalten, damit der Room gleich reagiert wie im Original
+	select {
+	case err := <-provider.ErrorChan():
+		if !errors.Is(err, transcription.ErrNetwork) {
+			t.Errorf("Expected ErrNetwork, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for replayed error")
+	}
+}
+
+func TestStreamer(t *testing.T) {
+	rec, err := replay.Load(recordMock(t))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	streamer, err := replay.Stream(rec, mock.New(), replay.MaxSpeed)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer streamer.Close()
+	if err := streamer.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	// Audio vom Host wird verworfen, nur die Aufnahme zählt
+	for i := 0; i < 10; i++ {
+		streamer.SendAudio([]byte("host-audio"))
+	}
+
+	// Vier aufgenommene Pakete ergeben zwei Wörter
+	for _, want := range []string{"Hallo", "Hallo zusammen,"} {
+		select {
+		case result := <-streamer.ResultChan():
+			if result.Text != want {
+				t.Errorf("Expected '%s', got '%s'", want, result.Text)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for '%s'", want)
+		}
+	}
+}
+
+func TestFind_Invalid(t *testing.T) {
+	for _, name := range []string{"", "../session", ".hidden", "missing"} {
+		if _, err := replay.Find(t.TempDir(), name); err == nil {
+			t.Errorf("Expected error for recording %q", name)
+		}
+	}
+	if _, err := replay.New(&replay.Recording{}, 0); err == nil {
+		t.Error("Expected error for speed 0")
+	}
+}
@@ -4,15 +4,63 @@ import (
 	"context"
 )
 
+// Word is a single recognized word. Start and End are offsets in seconds
+// relative to the beginning of the audio stream.
+type Word struct {
+	Text       string  `json:"text"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence"`
+	Speaker    string  `json:"speaker,omitempty"`
+}
+
 type TranscriptResult struct {
 	Text      string `json:"text"`
 	Speaker   string `json:"speaker,omitempty"` // Vorbereitung für Diarization
 	IsPartial bool   `json:"is_partial"`
+
+	// Start and End of the utterance in seconds since the stream started.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Words []Word  `json:"words,omitempty"`
+
+	// Language is the language the utterance was recognized in.
+	Language string `json:"language,omitempty"`
+
+	// Segment numbers the parts of a provider result that was split by speaker.
+	// Every segment is tracked as an utterance of its own.
+	Segment int `json:"segment,omitempty"`
+
+	// UtteranceID and Revision are assigned by the room, not by the provider.
+	// Partials and the final of one utterance share the ID; the revision grows with every update.
+	UtteranceID string `json:"utterance_id,omitempty"`
+	Revision    int    `json:"revision"`
+}
+
+// Shift moves the timings by offset seconds. Providers count from the start of
+// their connection, while a session may span several connections.
+// Results without timing stay unchanged.
+func (r *TranscriptResult) Shift(offset float64) {
+	if offset == 0 || r.End <= r.Start {
+		return
+	}
+	r.Start += offset
+	r.End += offset
+	if len(r.Words) == 0 {
+		return
+	}
+	words := make([]Word, len(r.Words)) // Der Provider kann den Slice noch halten
+	for i, w := range r.Words {
+		w.Start += offset
+		w.End += offset
+		words[i] = w
+	}
+	r.Words = words
 }
 
 type Service interface {
-	// Connect creates connection to the transcription service.
-	Connect(ctx context.Context) error
+	// Connect creates connection to the transcription service using the session options.
+	Connect(ctx context.Context, opts Options) error
 
 	// SendAudio takes audio chunk and sends it to the transcription service.
 	// Should be thread-safe and non-blocking.
@@ -28,3 +76,9 @@ type Service interface {
 	// Close closes the connection to the transcription service.
 	Close() error
 }
+
+// Flusher is implemented by services that can finalize the pending utterance on request,
+// e.g. when the host stops streaming.
+type Flusher interface {
+	Flush() error
+}
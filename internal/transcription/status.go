@@ -0,0 +1,21 @@
+package transcription
+
+// Connection states reported via StatusReporter
+const (
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+)
+
+// Status describes the connection state of a service that recovers from failures on its own.
+type Status struct {
+	State   string `json:"state"`
+	Attempt int    `json:"attempt,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// BufferedBytes is the amount of audio held back during the outage.
+	BufferedBytes int `json:"buffered_bytes,omitempty"`
+}
+
+// StatusReporter is implemented by services that report reconnects.
+type StatusReporter interface {
+	StatusChan() <-chan Status
+}
@@ -0,0 +1,55 @@
+package stdio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Frame types of the framed protocol. Every frame is a one byte type,
+// a four byte big-endian payload length and the payload.
+const (
+	// Go -> worker
+	FrameAudio  byte = 'A' // Raw audio
+	FrameConfig byte = 'C' // JSON session config
+	FrameFlush  byte = 'F' // Finalize the pending utterance
+	FrameStop   byte = 'S' // Graceful shutdown
+
+	// Worker -> Go
+	FrameResult    byte = 'R' // JSON transcription.TranscriptResult
+	FrameReady     byte = 'Y' // Worker is connected and accepts audio
+	FrameHeartbeat byte = 'H' // Liveness signal
+	FrameError     byte = 'E' // JSON {"kind": ..., "message": ...}
+	FrameActivity  byte = 'V' // JSON transcription.SpeechEvent
+)
+
+// maxFrameSize protects against corrupt length prefixes.
+const maxFrameSize = 16 << 20
+
+// WriteFrame writes a single frame.
+func WriteFrame(w io.Writer, frameType byte, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = frameType
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// ReadFrame reads a single frame.
+func ReadFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds limit", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
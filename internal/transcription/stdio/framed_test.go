@@ -0,0 +1,227 @@
+package stdio_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/stdio"
+)
+
+var _ transcription.Flusher = (*stdio.Provider)(nil)
+
+// TestMain lässt das Test-Binary selbst als Framed-Worker laufen, wenn STDIO_TEST_WORKER gesetzt ist
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("STDIO_TEST_WORKER"); mode != "" {
+		runFramedWorker(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFramedWorker echoes every frame as result. Mode "silent" never gets ready,
+// mode "hang" gets ready but never sends a heartbeat. Mode "once" crashes on the
+// first audio frame and stays silent after the restart.
+func runFramedWorker(mode string) {
+	frameType, payload, err := stdio.ReadFrame(os.Stdin)
+	if err != nil || frameType != stdio.FrameConfig {
+		os.Exit(2)
+	}
+	if mode == "once" {
+		if _, err := os.Stat(os.Getenv("STDIO_TEST_MARKER")); err == nil {
+			mode = "silent"
+		} else {
+			os.WriteFile(os.Getenv("STDIO_TEST_MARKER"), nil, 0o600)
+		}
+	}
+	if mode == "silent" {
+		time.Sleep(time.Minute)
+		return
+	}
+	stdio.WriteFrame(os.Stdout, stdio.FrameReady, nil)
+
+	result := func(text string, partial bool) {
+		data, _ := json.Marshal(transcription.TranscriptResult{Text: text, IsPartial: partial})
+		stdio.WriteFrame(os.Stdout, stdio.FrameResult, data)
+	}
+	var cfg struct {
+		Language   string   `json:"language"`
+		Vocabulary []string `json:"vocabulary"`
+	}
+	json.Unmarshal(payload, &cfg)
+	result("config:"+cfg.Language+":"+strings.Join(cfg.Vocabulary, ","), true)
+
+	for {
+		frameType, payload, err := stdio.ReadFrame(os.Stdin)
+		if err != nil {
+			return
+		}
+		switch frameType {
+		case stdio.FrameAudio:
+			if mode == "once" {
+				os.Exit(1)
+			}
+			result("audio:"+string(payload), true)
+		case stdio.FrameConfig:
+			json.Unmarshal(payload, &cfg)
+			result("vocabulary:"+strings.Join(cfg.Vocabulary, ","), true)
+		case stdio.FrameFlush:
+			result("flushed", false)
+		case stdio.FrameStop:
+			return
+		}
+	}
+}
+
+func framedProvider(mode string) *stdio.Provider {
+	return stdio.New(stdio.Config{
+		Name:             "Framed",
+		Command:          os.Args[0],
+		Env:              []string{"STDIO_TEST_WORKER=" + mode},
+		Protocol:         stdio.ProtocolFramed,
+		ReadyTimeout:     500 * time.Millisecond,
+		HeartbeatTimeout: 400 * time.Millisecond,
+	})
+}
+
+func TestFrame_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := stdio.WriteFrame(&buf, stdio.FrameAudio, []byte("pcm")); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+	if err := stdio.WriteFrame(&buf, stdio.FrameStop, nil); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+
+	frameType, payload, err := stdio.ReadFrame(&buf)
+	if err != nil || frameType != stdio.FrameAudio || string(payload) != "pcm" {
+		t.Errorf("Expected audio frame 'pcm', got %q %q (%v)", frameType, payload, err)
+	}
+	frameType, payload, err = stdio.ReadFrame(&buf)
+	if err != nil || frameType != stdio.FrameStop || len(payload) != 0 {
+		t.Errorf("Expected empty stop frame, got %q %q (%v)", frameType, payload, err)
+	}
+}
+
+func TestFrame_TooLarge(t *testing.T) {
+	header := []byte{stdio.FrameAudio, 0xff, 0xff, 0xff, 0xff}
+	if _, _, err := stdio.ReadFrame(bytes.NewReader(header)); err == nil {
+		t.Error("Expected error for oversized frame")
+	}
+}
+
+func TestProvider_Framed(t *testing.T) {
+	// Der Test-Worker sendet keine Heartbeats, daher gilt hier der Standard-Timeout
+	provider := stdio.New(stdio.Config{
+		Name:     "Framed",
+		Command:  os.Args[0],
+		Env:      []string{"STDIO_TEST_WORKER=echo"},
+		Protocol: stdio.ProtocolFramed,
+	})
+
+	err := provider.Connect(context.Background(), transcription.Options{
+		Language:   "en-US",
+		Vocabulary: []string{"Tolka"},
+	})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	if err := provider.SendAudio([]byte("hello")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+	if err := provider.UpdateVocabulary([]string{"Azure", "Deepgram"}); err != nil {
+		t.Fatalf("UpdateVocabulary failed: %v", err)
+	}
+	if err := provider.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	expected := []string{"config:en-US:Tolka", "audio:hello", "vocabulary:Azure,Deepgram", "flushed"}
+	for _, want := range expected {
+		select {
+		case got := <-provider.ResultChan():
+			if got.Text != want {
+				t.Errorf("Expected %q, got %q", want, got.Text)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for %q", want)
+		}
+	}
+}
+
+func TestProvider_FramedReadyTimeout(t *testing.T) {
+	provider := framedProvider("silent")
+	defer provider.Close()
+
+	err := provider.Connect(context.Background(), transcription.Options{})
+	if !errors.Is(err, transcription.ErrWorkerExited) {
+		t.Errorf("Expected ErrWorkerExited, got %v", err)
+	}
+}
+
+func TestProvider_FramedHeartbeatTimeout(t *testing.T) {
+	provider := framedProvider("hang")
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	select {
+	case err := <-provider.ErrorChan():
+		if !errors.Is(err, transcription.ErrWorkerExited) || !strings.Contains(err.Error(), "heartbeat") {
+			t.Errorf("Expected heartbeat failure, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for heartbeat failure")
+	}
+}
+
+func TestProvider_FramedRestartDoesNotBlock(t *testing.T) {
+	// Der neu gestartete Worker wird nie bereit; Audio und Close dürfen darauf nicht warten
+	provider := stdio.New(stdio.Config{
+		Name:          "Framed",
+		Command:       os.Args[0],
+		Env:           []string{"STDIO_TEST_WORKER=once", "STDIO_TEST_MARKER=" + filepath.Join(t.TempDir(), "started")},
+		Protocol:      stdio.ProtocolFramed,
+		ReadyTimeout:  time.Minute,
+		MaxRestarts:   1,
+		RestartWindow: time.Minute,
+	})
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	<-provider.ResultChan() // config
+
+	if err := provider.SendAudio([]byte("crash")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond) // Neustart läuft
+
+	done := make(chan error, 1)
+	go func() { done <- provider.SendAudio([]byte("audio")) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected error while the worker restarts")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendAudio blocked while the worker restarts")
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- provider.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked while the worker restarts")
+	}
+}
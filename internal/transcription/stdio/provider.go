@@ -0,0 +1,726 @@
+// Package stdio runs a local transcription engine as child process.
+//
+// Two protocols are supported. With ProtocolLines (default) audio is written raw to
+// the process' stdin and results are read as JSON lines from stdout, e.g.
+//
+//	{"text": "Hallo zusammen", "is_partial": false, "speaker": "Guest-1", "start": 0.4, "end": 1.6}
+//
+// Lines with "type": "error" report a failure instead, e.g.
+//
+//	{"type": "error", "kind": "auth", "message": "Invalid subscription key"}
+//
+// where kind is one of auth, quota, network or service. Speech activity is reported
+// as {"type": "speech_start"} or {"type": "speech_end", "speaker": "Guest-1", "time": 3.2}.
+// Commands like vocabulary
+// updates are written as JSON lines to file descriptor 3.
+//
+// With ProtocolFramed both directions use length-prefixed frames (see frame.go).
+// Go sends the session config as first frame and Connect waits for the worker's
+// ready frame. The worker sends heartbeats; a silent worker is killed.
+//
+// In both modes the session options are also passed as environment variables
+// (TOLKA_LANGUAGE, TOLKA_LANGUAGES, TOLKA_AUTO_DETECT, TOLKA_AUDIO_FORMAT) and
+// lines on stderr end up in the room's log.
+//
+// The worker is supervised: if it exits unexpectedly it is restarted up to
+// MaxRestarts times within RestartWindow before the failure is reported on ErrorChan.
+package stdio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// Protocol selects how Go and the worker talk to each other.
+type Protocol string
+
+const (
+	ProtocolLines  Protocol = "lines"
+	ProtocolFramed Protocol = "framed"
+)
+
+// Config describes the engine process.
+type Config struct {
+	// Name is used in logs and errors, e.g. "Azure" or "Whisper".
+	Name    string
+	Command string
+	Args    []string
+	// Env holds additional KEY=VALUE pairs on top of the server environment.
+	Env []string
+	// AudioFormat is the format the engine expects on stdin.
+	AudioFormat transcription.AudioFormat
+	Protocol    Protocol
+
+	// MaxRestarts is the number of restarts allowed within RestartWindow. Zero disables restarts.
+	MaxRestarts   int
+	RestartWindow time.Duration
+
+	// ReadyTimeout and HeartbeatTimeout apply to ProtocolFramed only.
+	ReadyTimeout     time.Duration
+	HeartbeatTimeout time.Duration
+}
+
+// Capabilities returns the capabilities of a generic engine.
+// Only partial results are assumed, every language is accepted.
+func (c Config) Capabilities() transcription.Capabilities {
+	return transcription.Capabilities{
+		Partials:    true,
+		AudioFormat: c.AudioFormat,
+	}
+}
+
+// worker is a single run of the engine process.
+type worker struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	control io.WriteCloser // JSON-Kommandos an den Prozess (fd 3), nur ProtocolLines
+
+	ready    chan struct{} // Closed on the ready frame
+	output   chan struct{} // Closed when stdout ends
+	exited   chan struct{} // Closed when the process has been waited for
+	exitErr  error         // Set before exited is closed
+	lastSeen atomic.Int64  // Unix nanos of the last frame
+}
+
+type Provider struct {
+	cfg Config
+
+	ctx    context.Context
+	opts   transcription.Options
+	logger *slog.Logger
+
+	resChan  chan transcription.TranscriptResult
+	errChan  chan error
+	activity chan transcription.SpeechEvent
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	worker   *worker
+	restarts []time.Time
+	isClosed bool
+	closing  chan struct{} // Closed by Close, aborts a worker that is still starting
+
+	// Stream times restart at zero with every worker. Results of a restarted worker
+	// are moved behind the last result of the previous ones. Only one worker emits at a time.
+	offset float64
+	end    float64
+
+	errMu     sync.Mutex
+	lastError error // Last error reported by the worker itself
+}
+
+func New(cfg Config) *Provider {
+	if cfg.Name == "" {
+		cfg.Name = "Exec"
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolLines
+	}
+	if cfg.ReadyTimeout == 0 {
+		cfg.ReadyTimeout = 30 * time.Second
+	}
+	if cfg.HeartbeatTimeout == 0 {
+		cfg.HeartbeatTimeout = 20 * time.Second
+	}
+	return &Provider{
+		cfg:      cfg,
+		resChan:  make(chan transcription.TranscriptResult, 100),
+		errChan:  make(chan error, 10),
+		activity: make(chan transcription.SpeechEvent, 10),
+		closing:  make(chan struct{}),
+	}
+}
+
+// controlCommand is written as JSON line to the control pipe
+type controlCommand struct {
+	Type    string   `json:"type"`
+	Phrases []string `json:"phrases,omitempty"`
+}
+
+// sessionConfig is the payload of config frames. Language settings only apply to the first one.
+type sessionConfig struct {
+	Language   string   `json:"language,omitempty"`
+	Languages  []string `json:"languages,omitempty"`
+	AutoDetect bool     `json:"auto_detect,omitempty"`
+	Vocabulary []string `json:"vocabulary"`
+}
+
+// workerLine is a line on stdout: either a result or an event with a type
+type workerLine struct {
+	Type    string  `json:"type"`
+	Kind    string  `json:"kind"`
+	Message string  `json:"message"`
+	Time    float64 `json:"time"`
+	transcription.TranscriptResult
+}
+
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return errors.New("provider is closed")
+	}
+	if p.cfg.Command == "" {
+		p.mu.Unlock()
+		return fmt.Errorf("%s: no command configured", p.cfg.Name)
+	}
+	p.ctx, p.opts = ctx, opts
+	p.logger = transcription.LoggerFrom(ctx).With("provider", p.cfg.Name)
+	p.mu.Unlock()
+
+	w, err := p.start(opts)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.install(w, opts)
+}
+
+// install makes a started worker the active one and supervises it. opts are the
+// options it was started with. Callers must hold p.mu.
+func (p *Provider) install(w *worker, opts transcription.Options) error {
+	if p.isClosed {
+		p.abandon(w)
+		return errors.New("provider is closed")
+	}
+	if !slices.Equal(opts.Vocabulary, p.opts.Vocabulary) {
+		// Während des Starts geändert
+		if err := p.sendVocabulary(w, p.opts.Vocabulary); err != nil {
+			p.logger.Warn("Failed to send vocabulary", "error", err)
+		}
+	}
+	p.worker = w
+	p.wg.Add(1)
+	go p.supervise(w)
+	return nil
+}
+
+// start spawns a new worker process and waits until it is ready. It runs without
+// p.mu, so audio, Close and vocabulary updates are not blocked while a framed
+// worker starts; the caller installs the worker afterwards.
+func (p *Provider) start(opts transcription.Options) (*worker, error) {
+	cmd := exec.CommandContext(p.ctx, p.cfg.Command, p.cfg.Args...)
+	cmd.Env = append(os.Environ(), p.cfg.Env...)
+	cmd.Env = append(cmd.Env, sessionEnv(opts, p.cfg.AudioFormat)...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &worker{
+		cmd:    cmd,
+		stdin:  stdin,
+		ready:  make(chan struct{}),
+		output: make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	w.lastSeen.Store(time.Now().UnixNano())
+
+	// Kontroll-Pipe: Der Prozess liest JSON-Kommandos von fd 3, stdin bleibt reines Audio
+	var controlReader *os.File
+	if p.cfg.Protocol == ProtocolLines {
+		var controlWriter *os.File
+		controlReader, controlWriter, err = os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		cmd.ExtraFiles = []*os.File{controlReader}
+		w.control = controlWriter
+	}
+
+	if err := cmd.Start(); err != nil {
+		if controlReader != nil {
+			controlReader.Close()
+			w.control.Close()
+		}
+		return nil, fmt.Errorf("%s: starting worker failed: %w", p.cfg.Name, err)
+	}
+	if controlReader != nil {
+		controlReader.Close() // Gehört jetzt dem Kindprozess
+	}
+	p.logger.Info("Worker started", "command", p.cfg.Command, "pid", cmd.Process.Pid, "protocol", p.cfg.Protocol)
+
+	p.wg.Add(1)
+	go p.drain(w, stdout, stderr)
+
+	if p.cfg.Protocol == ProtocolLines {
+		if len(opts.Vocabulary) > 0 {
+			if err := p.sendVocabulary(w, opts.Vocabulary); err != nil {
+				p.logger.Warn("Failed to send vocabulary", "error", err)
+			}
+		}
+		return w, nil
+	}
+
+	if err := p.sendConfig(w, sessionConfig{
+		Language:   opts.Language,
+		Languages:  opts.Candidates(),
+		AutoDetect: opts.AutoDetect,
+		Vocabulary: opts.Vocabulary,
+	}); err != nil {
+		p.abandon(w)
+		return nil, fmt.Errorf("%s: sending config failed: %w", p.cfg.Name, err)
+	}
+
+	select {
+	case <-w.ready:
+		p.logger.Info("Worker ready")
+	case <-w.output:
+		p.abandon(w)
+		return nil, p.startupError("worker exited before it was ready")
+	case <-p.closing:
+		p.abandon(w)
+		return nil, errors.New("provider is closed")
+	case <-time.After(p.cfg.ReadyTimeout):
+		p.abandon(w)
+		return nil, p.startupError(fmt.Sprintf("worker not ready after %v", p.cfg.ReadyTimeout))
+	}
+	return w, nil
+}
+
+// startupError prefers the error the worker reported itself.
+func (p *Provider) startupError(detail string) error {
+	if err := p.takeLastError(); err != nil {
+		return err
+	}
+	return &transcription.ProviderError{Provider: p.cfg.Name, Kind: transcription.ErrWorkerExited, Detail: detail}
+}
+
+// abandon kills a worker that failed to start. It was never installed, so nothing restarts it.
+func (p *Provider) abandon(w *worker) {
+	w.stdin.Close()
+	if w.control != nil {
+		w.control.Close()
+	}
+	w.cmd.Process.Kill()
+}
+
+// sessionEnv passes the session options to the engine.
+func sessionEnv(opts transcription.Options, format transcription.AudioFormat) []string {
+	return []string{
+		"TOLKA_LANGUAGE=" + opts.Language,
+		"TOLKA_LANGUAGES=" + strings.Join(opts.Candidates(), ","),
+		"TOLKA_AUTO_DETECT=" + strconv.FormatBool(opts.AutoDetect),
+		"TOLKA_AUDIO_FORMAT=" + string(format),
+	}
+}
+
+// drain reads the worker's output until it ends and waits for the process to exit.
+func (p *Provider) drain(w *worker, stdout, stderr io.Reader) {
+	defer p.wg.Done()
+
+	var logs sync.WaitGroup
+	logs.Add(1)
+	go func() {
+		defer logs.Done()
+		p.forwardLogs(stderr)
+	}()
+
+	if p.cfg.Protocol == ProtocolFramed {
+		stopWatchdog := make(chan struct{})
+		go p.watchdog(w, stopWatchdog)
+		p.readFrames(w, stdout)
+		close(stopWatchdog)
+	} else {
+		p.readLines(stdout)
+	}
+	close(w.output)
+
+	logs.Wait()
+	w.exitErr = w.cmd.Wait()
+	close(w.exited)
+}
+
+// supervise waits for the installed worker to exit and restarts it if allowed.
+func (p *Provider) supervise(w *worker) {
+	defer p.wg.Done()
+	<-w.exited
+
+	p.mu.Lock()
+	if p.isClosed || p.ctx.Err() != nil || p.worker != w {
+		p.mu.Unlock()
+		return // Closed or already replaced
+	}
+	w.stdin.Close()
+	if w.control != nil {
+		w.control.Close()
+	}
+	p.worker = nil
+	p.offset = p.end
+
+	cause := p.takeLastError()
+	p.logger.Warn("Worker exited unexpectedly", "error", w.exitErr, "cause", cause)
+
+	if transcription.IsPermanent(cause) {
+		p.mu.Unlock()
+		return // Already reported, a restart cannot help
+	}
+
+	restart, opts := p.allowRestart(), p.opts
+	p.mu.Unlock()
+
+	kind := transcription.ErrRestartsExhausted
+	if restart {
+		kind = transcription.ErrWorkerExited // Der Neustart selbst ist fehlgeschlagen
+		next, err := p.start(opts)
+		if err == nil {
+			p.mu.Lock()
+			err = p.install(next, opts)
+			p.mu.Unlock()
+		}
+		if err == nil || p.closed() {
+			return
+		}
+		cause = err
+	}
+
+	detail := fmt.Sprint(w.exitErr)
+	if cause != nil {
+		detail = fmt.Sprintf("%v (%v)", w.exitErr, cause)
+	}
+	p.report(&transcription.ProviderError{
+		Provider: p.cfg.Name,
+		Kind:     kind,
+		Detail:   detail,
+	})
+}
+
+func (p *Provider) closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isClosed
+}
+
+// watchdog kills a framed worker that stopped sending heartbeats.
+// Startup is covered by ReadyTimeout instead.
+func (p *Provider) watchdog(w *worker, stop <-chan struct{}) {
+	select {
+	case <-stop:
+		return
+	case <-w.ready:
+	}
+
+	ticker := time.NewTicker(p.cfg.HeartbeatTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			silent := time.Since(time.Unix(0, w.lastSeen.Load()))
+			if silent > p.cfg.HeartbeatTimeout {
+				p.logger.Error("Worker stopped sending heartbeats, killing it", "silent", silent)
+				p.setLastError(&transcription.ProviderError{
+					Provider: p.cfg.Name,
+					Kind:     transcription.ErrService,
+					Detail:   fmt.Sprintf("no heartbeat within %v", p.cfg.HeartbeatTimeout),
+				})
+				w.cmd.Process.Kill()
+				return
+			}
+		}
+	}
+}
+
+// allowRestart checks the restart budget. Callers must hold p.mu.
+func (p *Provider) allowRestart() bool {
+	now := time.Now()
+	recent := p.restarts[:0]
+	for _, t := range p.restarts {
+		if now.Sub(t) < p.cfg.RestartWindow {
+			recent = append(recent, t)
+		}
+	}
+	p.restarts = recent
+
+	if len(p.restarts) >= p.cfg.MaxRestarts {
+		p.logger.Error("Restart budget exhausted", "max_restarts", p.cfg.MaxRestarts, "window", p.cfg.RestartWindow)
+		return false
+	}
+	p.restarts = append(p.restarts, now)
+	p.logger.Info("Restarting worker", "restart", len(p.restarts))
+	return true
+}
+
+func (p *Provider) readLines(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+
+	// Liest Zeile für Zeile (JSON) vom Prozess
+	for scanner.Scan() {
+		var line workerLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			p.logger.Warn("Failed to parse JSON from worker", "error", err, "line", scanner.Text())
+			continue
+		}
+
+		switch line.Type {
+		case "error":
+			p.handleWorkerError(line.Kind, line.Message)
+		case transcription.SpeechStart, transcription.SpeechEnd:
+			p.reportActivity(transcription.SpeechEvent{Type: line.Type, Speaker: line.Speaker, Time: line.Time})
+		default:
+			p.emit(line.TranscriptResult)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		p.logger.Warn("Error reading from worker", "error", err)
+	}
+}
+
+func (p *Provider) readFrames(w *worker, r io.Reader) {
+	for {
+		frameType, payload, err := ReadFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				p.logger.Warn("Error reading from worker", "error", err)
+			}
+			return
+		}
+		w.lastSeen.Store(time.Now().UnixNano())
+
+		switch frameType {
+		case FrameResult:
+			var res transcription.TranscriptResult
+			if err := json.Unmarshal(payload, &res); err != nil {
+				p.logger.Warn("Failed to parse result frame", "error", err)
+				continue
+			}
+			p.emit(res)
+		case FrameReady:
+			select {
+			case <-w.ready:
+			default:
+				close(w.ready)
+			}
+		case FrameHeartbeat:
+		case FrameActivity:
+			var event transcription.SpeechEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				p.logger.Warn("Failed to parse activity frame", "error", err)
+				continue
+			}
+			p.reportActivity(event)
+		case FrameError:
+			var event workerLine
+			if err := json.Unmarshal(payload, &event); err != nil {
+				p.logger.Warn("Failed to parse error frame", "error", err)
+				continue
+			}
+			p.handleWorkerError(event.Kind, event.Message)
+		default:
+			p.logger.Warn("Unknown frame type", "type", string(frameType))
+		}
+	}
+}
+
+func (p *Provider) emit(res transcription.TranscriptResult) {
+	if res.Text == "" {
+		return
+	}
+	res.Shift(p.offset)
+	p.end = max(p.end, res.End)
+	select {
+	case p.resChan <- res:
+	default:
+		// Drop frame if channel full
+	}
+}
+
+// handleWorkerError reports permanent failures immediately. Other failures are
+// remembered and only reported if the worker cannot be restarted.
+func (p *Provider) handleWorkerError(kind, message string) {
+	err := &transcription.ProviderError{
+		Provider: p.cfg.Name,
+		Kind:     transcription.ErrorKind(kind),
+		Detail:   message,
+	}
+	p.logger.Error("Worker reported error", "kind", kind, "message", message)
+	p.setLastError(err)
+
+	if transcription.IsPermanent(err) {
+		p.report(err)
+	}
+}
+
+func (p *Provider) setLastError(err error) {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	p.lastError = err
+}
+
+func (p *Provider) takeLastError() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	err := p.lastError
+	p.lastError = nil
+	return err
+}
+
+// forwardLogs writes the worker's stderr lines to the room's log.
+// Lines starting with a Python log level ("ERROR ...") keep their level.
+func (p *Provider) forwardLogs(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		level, msg := slog.LevelInfo, scanner.Text()
+		if prefix, rest, ok := strings.Cut(msg, " "); ok {
+			switch prefix {
+			case "DEBUG":
+				level, msg = slog.LevelDebug, rest
+			case "INFO":
+				level, msg = slog.LevelInfo, rest
+			case "WARNING":
+				level, msg = slog.LevelWarn, rest
+			case "ERROR", "CRITICAL":
+				level, msg = slog.LevelError, rest
+			}
+		}
+		p.logger.Log(context.Background(), level, msg, "stream", "stderr")
+	}
+}
+
+func (p *Provider) reportActivity(event transcription.SpeechEvent) {
+	if event.Time > 0 {
+		event.Time += p.offset
+	}
+	select {
+	case p.activity <- event:
+	default:
+	}
+}
+
+func (p *Provider) report(err error) {
+	select {
+	case p.errChan <- err:
+	default:
+	}
+}
+
+// sendVocabulary writes the phrase list to the worker. Callers must hold p.mu
+// once the worker is installed.
+func (p *Provider) sendVocabulary(w *worker, phrases []string) error {
+	if p.cfg.Protocol == ProtocolFramed {
+		return p.sendConfig(w, sessionConfig{Vocabulary: phrases})
+	}
+
+	line, err := json.Marshal(controlCommand{Type: "phrases", Phrases: phrases})
+	if err != nil {
+		return err
+	}
+	_, err = w.control.Write(append(line, '\n'))
+	return err
+}
+
+// sendConfig writes a config frame. Callers must hold p.mu once the worker is installed.
+func (p *Provider) sendConfig(w *worker, cfg sessionConfig) error {
+	payload, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(w.stdin, FrameConfig, payload)
+}
+
+// UpdateVocabulary forwards the new phrase list to the running process.
+func (p *Provider) UpdateVocabulary(phrases []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.opts.Vocabulary = phrases // Gilt auch nach einem Neustart
+	if p.isClosed || p.worker == nil {
+		return errors.New("worker not active")
+	}
+	return p.sendVocabulary(p.worker, phrases)
+}
+
+// Flush asks a framed worker to finalize the pending utterance.
+func (p *Provider) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed || p.worker == nil {
+		return errors.New("worker not active")
+	}
+	if p.cfg.Protocol != ProtocolFramed {
+		return nil
+	}
+	return WriteFrame(p.worker.stdin, FrameFlush, nil)
+}
+
+func (p *Provider) SendAudio(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed || p.worker == nil {
+		return errors.New("worker not active")
+	}
+
+	if p.cfg.Protocol == ProtocolFramed {
+		return WriteFrame(p.worker.stdin, FrameAudio, data)
+	}
+
+	// Audio Bytes direkt an Stdin schreiben
+	_, err := p.worker.stdin.Write(data)
+	return err
+}
+
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.isClosed = true
+	close(p.closing)
+
+	// Der Prozess beendet sich bei Stop bzw. EOF auf stdin, danach endet auch die Überwachung
+	if w := p.worker; w != nil {
+		if p.cfg.Protocol == ProtocolFramed {
+			WriteFrame(w.stdin, FrameStop, nil)
+		}
+		w.stdin.Close()
+		if w.control != nil {
+			w.control.Close()
+		}
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	close(p.resChan)
+	close(p.errChan)
+	if p.logger != nil {
+		p.logger.Info("Worker closed")
+	}
+	return nil
+}
+
+func (p *Provider) ResultChan() <-chan transcription.TranscriptResult { return p.resChan }
+func (p *Provider) ErrorChan() <-chan error                           { return p.errChan }
+func (p *Provider) ActivityChan() <-chan transcription.SpeechEvent    { return p.activity }
@@ -0,0 +1,133 @@
+package stdio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/stdio"
+)
+
+// Compile-time checks
+var _ transcription.Service = (*stdio.Provider)(nil)
+var _ transcription.VocabularyUpdater = (*stdio.Provider)(nil)
+
+// echoScript liest das Vokabular von fd 3 und meldet Sprache und Audio als JSON-Zeilen
+const echoScript = `
+read -r cmd <&3
+printf '{"type":"speech_start","speaker":"Engine","time":0.5}\n'
+printf '{"text":"%s","is_partial":true}\n' "$TOLKA_LANGUAGE"
+printf '{"text":"%s","is_partial":false,"speaker":"Engine"}\n' "$(head -c 5)"
+`
+
+func TestProvider_RoundTrip(t *testing.T) {
+	provider := stdio.New(stdio.Config{
+		Name:    "Test",
+		Command: "sh",
+		Args:    []string{"-c", echoScript},
+	})
+
+	err := provider.Connect(context.Background(), transcription.Options{
+		Language:   "en-US",
+		Vocabulary: []string{"Tolka"},
+	})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	if err := provider.SendAudio([]byte("audio")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+
+	expected := []transcription.TranscriptResult{
+		{Text: "en-US", IsPartial: true},
+		{Text: "audio", IsPartial: false, Speaker: "Engine"},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-provider.ResultChan():
+			if got.Text != want.Text || got.IsPartial != want.IsPartial || got.Speaker != want.Speaker {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for %q", want.Text)
+		}
+	}
+
+	select {
+	case event := <-provider.ActivityChan():
+		want := transcription.SpeechEvent{Type: transcription.SpeechStart, Speaker: "Engine", Time: 0.5}
+		if event != want {
+			t.Errorf("Expected %+v, got %+v", want, event)
+		}
+	default:
+		t.Error("Expected speech_start event")
+	}
+}
+
+func TestProvider_MissingCommand(t *testing.T) {
+	provider := stdio.New(stdio.Config{})
+
+	if err := provider.Connect(context.Background(), transcription.Options{}); err == nil {
+		t.Error("Expected error without configured command")
+	}
+}
+
+func TestProvider_RestartBudget(t *testing.T) {
+	// Der Worker stürzt sofort ab; nach einem Neustart ist das Budget aufgebraucht
+	provider := stdio.New(stdio.Config{
+		Name:          "Crashy",
+		Command:       "sh",
+		Args:          []string{"-c", "echo 'ERROR boom' >&2; exit 3"},
+		MaxRestarts:   1,
+		RestartWindow: time.Minute,
+	})
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	select {
+	case err := <-provider.ErrorChan():
+		if !errors.Is(err, transcription.ErrWorkerExited) || !transcription.IsPermanent(err) {
+			t.Errorf("Expected permanent ErrWorkerExited, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for worker exit error")
+	}
+}
+
+func TestProvider_PermanentWorkerError(t *testing.T) {
+	script := `echo '{"type":"error","kind":"auth","message":"invalid key"}'; exit 1`
+	provider := stdio.New(stdio.Config{
+		Name:          "Azure",
+		Command:       "sh",
+		Args:          []string{"-c", script},
+		MaxRestarts:   3,
+		RestartWindow: time.Minute,
+	})
+	if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	select {
+	case err := <-provider.ErrorChan():
+		var providerErr *transcription.ProviderError
+		if !errors.As(err, &providerErr) || !errors.Is(err, transcription.ErrAuth) {
+			t.Errorf("Expected ProviderError with ErrAuth, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for auth error")
+	}
+
+	// Kein Neustart bei Auth-Fehlern: es folgt kein weiterer Fehler
+	select {
+	case err := <-provider.ErrorChan():
+		t.Errorf("Unexpected second error: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
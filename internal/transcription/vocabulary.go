@@ -0,0 +1,31 @@
+package transcription
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxVocabulary limits the number of phrases per session.
+const MaxVocabulary = 100
+
+// VocabularyUpdater is implemented by services that accept vocabulary changes
+// while the session is running.
+type VocabularyUpdater interface {
+	UpdateVocabulary(phrases []string) error
+}
+
+// NormalizeVocabulary trims phrases and removes empty entries and duplicates.
+func NormalizeVocabulary(phrases []string) ([]string, error) {
+	var out []string
+	for _, phrase := range phrases {
+		phrase = strings.TrimSpace(phrase)
+		if phrase != "" && !containsFold(out, phrase) {
+			out = append(out, phrase)
+		}
+	}
+
+	if len(out) > MaxVocabulary {
+		return nil, fmt.Errorf("vocabulary has %d phrases, at most %d are allowed", len(out), MaxVocabulary)
+	}
+	return out, nil
+}
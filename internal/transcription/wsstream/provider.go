@@ -0,0 +1,337 @@
+// Package wsstream connects to self-hosted streaming ASR servers over WebSocket.
+// Audio is sent as binary messages, results arrive as JSON text messages and are
+// mapped onto transcription.TranscriptResult via configurable field paths.
+package wsstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// FieldMapping holds dotted JSON paths into the server messages, e.g.
+// "result.alternatives.0.text". Empty paths are not read.
+type FieldMapping struct {
+	Text     string
+	Speaker  string
+	Start    string
+	End      string
+	Language string
+	// Partial points to a boolean that is true for interim results.
+	Partial string
+	// Final points to a boolean that is true for final results. Used when Partial is empty.
+	Final string
+}
+
+// DefaultFields matches the JSON encoding of transcription.TranscriptResult.
+var DefaultFields = FieldMapping{
+	Text:     "text",
+	Speaker:  "speaker",
+	Start:    "start",
+	End:      "end",
+	Language: "language",
+	Partial:  "is_partial",
+}
+
+// ParseFieldMapping reads "key=path" pairs (comma separated) on top of DefaultFields.
+// Valid keys are text, speaker, start, end, language, is_partial and is_final.
+func ParseFieldMapping(spec string) (FieldMapping, error) {
+	fields := DefaultFields
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, path, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fields, fmt.Errorf("invalid field mapping %q", pair)
+		}
+		switch strings.TrimSpace(key) {
+		case "text":
+			fields.Text = path
+		case "speaker":
+			fields.Speaker = path
+		case "start":
+			fields.Start = path
+		case "end":
+			fields.End = path
+		case "language":
+			fields.Language = path
+		case "is_partial":
+			fields.Partial = path
+		case "is_final":
+			fields.Partial = ""
+			fields.Final = path
+		default:
+			return fields, fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return fields, nil
+}
+
+// Config describes the ASR server.
+type Config struct {
+	Name string
+	URL  string
+	// AuthHeader and AuthValue are sent with the handshake, e.g. "Authorization: Bearer ...".
+	AuthHeader  string
+	AuthValue   string
+	Fields      FieldMapping
+	AudioFormat transcription.AudioFormat
+}
+
+// Capabilities returns the capabilities derived from the field mapping.
+func (c Config) Capabilities() transcription.Capabilities {
+	return transcription.Capabilities{
+		Diarization: c.Fields.Speaker != "",
+		Partials:    c.Fields.Partial != "" || c.Fields.Final != "",
+		AudioFormat: c.AudioFormat,
+	}
+}
+
+// writeWait bounds every write to the server.
+const writeWait = 5 * time.Second
+
+type Provider struct {
+	cfg Config
+
+	conn       *websocket.Conn
+	readerDone chan struct{}
+	resChan    chan transcription.TranscriptResult
+	errChan    chan error
+
+	mu       sync.Mutex
+	isClosed bool
+}
+
+func New(cfg Config) *Provider {
+	if cfg.Name == "" {
+		cfg.Name = "WebSocket"
+	}
+	if cfg.Fields == (FieldMapping{}) {
+		cfg.Fields = DefaultFields
+	}
+	return &Provider{
+		cfg:     cfg,
+		resChan: make(chan transcription.TranscriptResult, 100),
+		errChan: make(chan error, 10),
+	}
+}
+
+func (p *Provider) Connect(ctx context.Context, opts transcription.Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed {
+		return errors.New("provider is closed")
+	}
+
+	endpoint, err := sessionURL(p.cfg.URL, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p.cfg.Name, err)
+	}
+
+	header := http.Header{}
+	if p.cfg.AuthHeader != "" {
+		header.Set(p.cfg.AuthHeader, p.cfg.AuthValue)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, endpoint, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("%s: handshake failed with status %d: %w", p.cfg.Name, resp.StatusCode, err)
+		}
+		return fmt.Errorf("%s: %w", p.cfg.Name, err)
+	}
+	p.conn = conn
+
+	p.readerDone = make(chan struct{})
+	go p.readLoop()
+
+	log.Printf("%s: Connected to %s", p.cfg.Name, p.cfg.URL)
+	return nil
+}
+
+// sessionURL adds the session language as query parameters.
+func sessionURL(raw string, opts transcription.Options) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if opts.Language != "" {
+		q.Set("language", opts.Language)
+	}
+	if opts.AutoDetect {
+		q.Set("languages", strings.Join(opts.Candidates(), ","))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (p *Provider) readLoop() {
+	defer close(p.readerDone)
+
+	for {
+		msgType, payload, err := p.conn.ReadMessage()
+		if err != nil {
+			// Every close we did not ask for ends the stream, also a normal one:
+			// the room has to reconnect or fail over
+			if !p.closed() {
+				detail := err.Error()
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					detail = "server closed the connection"
+				}
+				select {
+				case p.errChan <- &transcription.ProviderError{Provider: p.cfg.Name, Kind: transcription.ErrNetwork, Detail: detail}:
+				default:
+				}
+			}
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+
+		result, ok, err := p.cfg.Fields.decode(payload)
+		if err != nil {
+			log.Printf("%s: Failed to parse message: %v", p.cfg.Name, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		select {
+		case p.resChan <- result:
+		default:
+			log.Printf("%s: Warning - Result channel full", p.cfg.Name)
+		}
+	}
+}
+
+// decode maps a server message onto a result. ok is false for messages without text.
+func (f FieldMapping) decode(payload []byte) (transcription.TranscriptResult, bool, error) {
+	var msg interface{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return transcription.TranscriptResult{}, false, err
+	}
+
+	result := transcription.TranscriptResult{
+		Text:     strings.TrimSpace(lookupString(msg, f.Text)),
+		Speaker:  lookupString(msg, f.Speaker),
+		Start:    lookupFloat(msg, f.Start),
+		End:      lookupFloat(msg, f.End),
+		Language: lookupString(msg, f.Language),
+	}
+	if f.Partial != "" {
+		result.IsPartial = lookupBool(msg, f.Partial)
+	} else if f.Final != "" {
+		result.IsPartial = !lookupBool(msg, f.Final)
+	}
+
+	return result, result.Text != "", nil
+}
+
+// lookup walks a dotted path through objects and arrays.
+func lookup(value interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[key]
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil
+			}
+			value = node[idx]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func lookupString(value interface{}, path string) string {
+	switch v := lookup(value, path).(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func lookupFloat(value interface{}, path string) float64 {
+	if v, ok := lookup(value, path).(float64); ok {
+		return v
+	}
+	return 0
+}
+
+func lookupBool(value interface{}, path string) bool {
+	v, _ := lookup(value, path).(bool)
+	return v
+}
+
+func (p *Provider) closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isClosed
+}
+
+func (p *Provider) SendAudio(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed || p.conn == nil {
+		return errors.New("websocket not connected")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	// A stalled server must not block Close, which waits for p.mu
+	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return p.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.isClosed = true
+
+	if p.conn != nil {
+		p.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+		p.conn.Close()
+	}
+	p.mu.Unlock()
+
+	if p.readerDone != nil {
+		<-p.readerDone
+	}
+
+	close(p.resChan)
+	close(p.errChan)
+	log.Printf("%s: Connection closed", p.cfg.Name)
+	return nil
+}
+
+func (p *Provider) ResultChan() <-chan transcription.TranscriptResult { return p.resChan }
+func (p *Provider) ErrorChan() <-chan error                           { return p.errChan }
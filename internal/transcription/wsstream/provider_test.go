@@ -0,0 +1,152 @@
+package wsstream_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/wsstream"
+)
+
+// Compile-time check
+var _ transcription.Service = (*wsstream.Provider)(nil)
+
+// newASRServer simuliert einen ASR-Server: Jedes Audio-Paket wird als Partial und Final zurückgeschickt.
+func newASRServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		lang := r.URL.Query().Get("language")
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		for {
+			msgType, audio, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType != websocket.BinaryMessage {
+				continue
+			}
+			for _, final := range []bool{false, true} {
+				msg := fmt.Sprintf(`{"result":{"final":%t,"hyps":[{"transcript":"%s"}],"spk":2,"t0":1.5},"lang":"%s"}`, final, audio, lang)
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+					return
+				}
+			}
+		}
+	}))
+}
+
+func TestProvider_RoundTrip(t *testing.T) {
+	server := newASRServer(t)
+	defer server.Close()
+
+	fields, err := wsstream.ParseFieldMapping("text=result.hyps.0.transcript,is_final=result.final,speaker=result.spk,start=result.t0,language=lang")
+	if err != nil {
+		t.Fatalf("ParseFieldMapping failed: %v", err)
+	}
+
+	provider := wsstream.New(wsstream.Config{
+		URL:        "ws" + strings.TrimPrefix(server.URL, "http"),
+		AuthHeader: "Authorization",
+		AuthValue:  "Bearer secret",
+		Fields:     fields,
+	})
+	if err := provider.Connect(context.Background(), transcription.Options{Language: "fr-CH"}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer provider.Close()
+
+	if err := provider.SendAudio([]byte("bonjour")); err != nil {
+		t.Fatalf("SendAudio failed: %v", err)
+	}
+
+	for _, partial := range []bool{true, false} {
+		select {
+		case result := <-provider.ResultChan():
+			if result.Text != "bonjour" || result.IsPartial != partial {
+				t.Errorf("Expected 'bonjour' (partial %t), got %+v", partial, result)
+			}
+			if result.Speaker != "2" || result.Start != 1.5 || result.Language != "fr-CH" {
+				t.Errorf("Field mapping not applied: %+v", result)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for result")
+		}
+	}
+}
+
+func TestProvider_Unauthorized(t *testing.T) {
+	server := newASRServer(t)
+	defer server.Close()
+
+	provider := wsstream.New(wsstream.Config{URL: "ws" + strings.TrimPrefix(server.URL, "http")})
+
+	err := provider.Connect(context.Background(), transcription.Options{})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected handshake error with status 401, got %v", err)
+	}
+}
+
+func TestProvider_ServerDisconnect(t *testing.T) {
+	for name, disconnect := range map[string]func(*websocket.Conn){
+		"abort": func(conn *websocket.Conn) {}, // Abbruch ohne Close-Frame
+		"normal close": func(conn *websocket.Conn) {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				disconnect(conn)
+				conn.Close()
+			}))
+			defer server.Close()
+
+			provider := wsstream.New(wsstream.Config{URL: "ws" + strings.TrimPrefix(server.URL, "http")})
+			if err := provider.Connect(context.Background(), transcription.Options{}); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer provider.Close()
+
+			select {
+			case err := <-provider.ErrorChan():
+				if !errors.Is(err, transcription.ErrNetwork) {
+					t.Errorf("Expected a network error after server disconnect, got %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timeout waiting for error")
+			}
+		})
+	}
+}
+
+func TestParseFieldMapping_Invalid(t *testing.T) {
+	if _, err := wsstream.ParseFieldMapping("text"); err == nil {
+		t.Error("Expected error for pair without '='")
+	}
+	if _, err := wsstream.ParseFieldMapping("color=x"); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
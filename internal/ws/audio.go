@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/joshuabeny1999/tolka/internal/audiofile"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// audioRecording stores the host's audio of a session created with audio consent.
+// The first part is "<room>.<ext>"; every new WebM stream, e.g. after the host
+// reconnected, starts a new part "<room>-2.<ext>", and so on. Parts are labelled
+// by the bytes the host sends, see audiofile.Detect.
+type audioRecording struct {
+	base string // Directory and room ID
+
+	mu     sync.Mutex
+	writer *audiofile.Writer
+	parts  int
+	closed bool
+}
+
+func newAudioRecording(base string) *audioRecording {
+	return &audioRecording{base: base}
+}
+
+// partBase returns the file name of a part without extension.
+func partBase(base string, part int) string {
+	if part <= 1 {
+		return base
+	}
+	return fmt.Sprintf("%s-%d", base, part)
+}
+
+// Write appends a chunk. The first chunk and every WebM header open a new part,
+// declared is the audio format of the providers.
+// After a write error the recording stops, the session continues.
+func (a *audioRecording) Write(declared transcription.AudioFormat, data []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return
+	}
+	if a.writer == nil || audiofile.IsWebMStart(data) {
+		a.closePart()
+		a.parts++
+		writer, err := audiofile.Create(partBase(a.base, a.parts), audiofile.Detect(data, declared))
+		if err != nil {
+			log.Printf("Audio recording failed: %v", err)
+			a.closed = true
+			return
+		}
+		a.writer = writer
+	}
+
+	if _, err := a.writer.Write(data); err != nil {
+		log.Printf("Audio recording failed: %v", err)
+		a.closePart()
+		a.closed = true
+	}
+}
+
+// Active reports whether parts may still be written.
+func (a *audioRecording) Active() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return !a.closed
+}
+
+// Close finishes the current part. Called when the room closes.
+func (a *audioRecording) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.closePart()
+	a.closed = true
+}
+
+// closePart finishes the current part. Callers must hold a.mu.
+func (a *audioRecording) closePart() {
+	if a.writer == nil {
+		return
+	}
+	if err := a.writer.Close(); err != nil {
+		log.Printf("Audio recording: Closing %s failed: %v", a.writer.Name(), err)
+	} else {
+		log.Printf("Audio recording: Stored %d bytes in %s", a.writer.Size(), a.writer.Name())
+	}
+	a.writer = nil
+}
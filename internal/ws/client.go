@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/store"
 )
 
 const (
@@ -15,10 +16,13 @@ const (
 )
 
 type ClientCommand struct {
-	Type      string `json:"type"`
-	SpeakerID string `json:"speakerId"`
-	Name      string `json:"name"`
-	Position  int    `json:"position"`
+	Type        string   `json:"type"`
+	SpeakerID   string   `json:"speakerId"`
+	Name        string   `json:"name"`
+	Position    int      `json:"position"`
+	Vocabulary  []string `json:"vocabulary"`
+	UtteranceID string   `json:"utteranceId"`
+	Text        string   `json:"text"`
 }
 
 type Client struct {
@@ -30,7 +34,10 @@ type Client struct {
 
 func (c *Client) readPump() {
 	defer func() {
-		c.room.unregister <- c
+		select {
+		case c.room.unregister <- c:
+		case <-c.room.ctx.Done(): // Room loop has ended
+		}
 		c.conn.Close()
 	}()
 
@@ -68,6 +75,22 @@ func (c *Client) readPump() {
 				if cmd.Type == "get_speakers" {
 					c.room.SendCurrentSpeakers(c)
 				}
+				if cmd.Type == "update_vocabulary" && c.isHost {
+					c.room.UpdateVocabulary(cmd.Vocabulary)
+				}
+				if cmd.Type == "get_vocabulary" {
+					c.room.SendVocabulary(c)
+				}
+				// Korrekturen finaler Segmente, nur durch den Host
+				if cmd.Type == "edit_segment" && c.isHost {
+					c.room.EditSegment(SegmentEdit{UtteranceID: cmd.UtteranceID, Action: store.EditText, Text: cmd.Text})
+				}
+				if cmd.Type == "reassign_segment" && c.isHost {
+					c.room.EditSegment(SegmentEdit{UtteranceID: cmd.UtteranceID, Action: store.EditSpeaker, Speaker: cmd.SpeakerID})
+				}
+				if cmd.Type == "delete_segment" && c.isHost {
+					c.room.EditSegment(SegmentEdit{UtteranceID: cmd.UtteranceID, Action: store.EditDelete})
+				}
 			}
 		}
 	}
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joshuabeny1999/tolka/internal/store"
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// HistoryLimits bound the transcript history of a room. Zero disables a bound.
+type HistoryLimits struct {
+	MaxSegments int
+	MaxAge      time.Duration
+}
+
+// DefaultHistoryLimits keep the last 500 final segments regardless of their age.
+var DefaultHistoryLimits = HistoryLimits{MaxSegments: 500}
+
+// HistorySegment is a final segment as kept in the room history.
+type HistorySegment = store.Segment
+
+// SegmentEdit is sent with "segment_edit" messages when the host corrected a segment
+type SegmentEdit = store.Edit
+
+// HistoryPayload is sent with "history" messages when a client registers
+type HistoryPayload struct {
+	Segments []HistorySegment `json:"segments"`
+}
+
+// transcriptHistory keeps the final segments of a room in order, so late joiners
+// and reconnecting clients can read the conversation from the start.
+type transcriptHistory struct {
+	limits HistoryLimits
+
+	mu       sync.Mutex
+	segments []HistorySegment
+}
+
+// Add appends a final segment and drops the oldest ones above the limits.
+func (h *transcriptHistory) Add(result transcription.TranscriptResult, now time.Time) {
+	if result.IsPartial {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.segments = append(h.segments, HistorySegment{TranscriptResult: result, ReceivedAt: now})
+	h.prune(now)
+}
+
+// Segments returns a copy of the segments within the limits.
+func (h *transcriptHistory) Segments(now time.Time) []HistorySegment {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.prune(now)
+	return append([]HistorySegment{}, h.segments...)
+}
+
+// Edit applies a host correction to the segment with its utterance ID and returns the
+// edit with the new revision. Segments dropped by the limits cannot be edited anymore.
+func (h *transcriptHistory) Edit(edit SegmentEdit) (SegmentEdit, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.segments) - 1; i >= 0; i-- {
+		if h.segments[i].UtteranceID != edit.UtteranceID {
+			continue
+		}
+		edit.Revision = h.segments[i].Revision + 1
+		edited, keep := edit.Apply(h.segments[i])
+		if keep {
+			h.segments[i] = edited
+		} else {
+			h.segments = append(h.segments[:i], h.segments[i+1:]...)
+		}
+		return edit, nil
+	}
+	return edit, fmt.Errorf("no segment %s in the history", edit.UtteranceID)
+}
+
+// prune drops the segments above the limits. Callers must hold h.mu.
+func (h *transcriptHistory) prune(now time.Time) {
+	drop := 0
+	if h.limits.MaxSegments > 0 && len(h.segments) > h.limits.MaxSegments {
+		drop = len(h.segments) - h.limits.MaxSegments
+	}
+	if h.limits.MaxAge > 0 {
+		for drop < len(h.segments) && now.Sub(h.segments[drop].ReceivedAt) > h.limits.MaxAge {
+			drop++
+		}
+	}
+	if drop > 0 {
+		// Copy so the dropped segments can be collected
+		h.segments = append([]HistorySegment(nil), h.segments[drop:]...)
+	}
+}
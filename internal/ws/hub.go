@@ -3,13 +3,21 @@ package ws
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/audiofile"
+	"github.com/joshuabeny1999/tolka/internal/export"
+	"github.com/joshuabeny1999/tolka/internal/store"
 	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/replay"
 )
 
 var upgrader = websocket.Upgrader{
@@ -19,37 +27,173 @@ var upgrader = websocket.Upgrader{
 }
 
 // ServiceFactory is a function that returns a new instance of a transcription service.
-type ServiceFactory func() transcription.Service
+// It returns an error if the provider cannot serve the requested options.
+type ServiceFactory func(opts transcription.Options) (transcription.Service, error)
+
+// ProviderInfo describes a registered provider for clients.
+type ProviderInfo struct {
+	Name         string                     `json:"name"`
+	Configured   bool                       `json:"configured"` // Credentials are present
+	Capabilities transcription.Capabilities `json:"capabilities"`
+	// Options lists the settings sessions may override, with their deployment defaults.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type providerEntry struct {
+	info    ProviderInfo
+	factory ServiceFactory
+}
 
 type Hub struct {
 	rooms     map[string]*Room
-	factories map[string]ServiceFactory
+	providers map[string]providerEntry
 	mu        sync.RWMutex
+
+	historyLimits HistoryLimits
+	store         store.Store // nil without persistence
+
+	// recordingsDir receives the recordings of sessions created with Options.Record
+	recordingsDir string
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		rooms:     make(map[string]*Room),
-		factories: make(map[string]ServiceFactory),
+		rooms:         make(map[string]*Room),
+		providers:     make(map[string]providerEntry),
+		historyLimits: DefaultHistoryLimits,
 	}
 }
 
-func (h *Hub) RegisterProvider(name string, factory ServiceFactory) {
-	h.factories[name] = factory
+// RegisterProvider makes a provider available for new sessions.
+// Unconfigured providers are only listed, sessions cannot be created with them.
+func (h *Hub) RegisterProvider(info ProviderInfo, factory ServiceFactory) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry := providerEntry{info: info}
+	if info.Configured {
+		entry.factory = factory
+	} else {
+		log.Printf("Provider %s is not configured, skipping registration", info.Name)
+	}
+	h.providers[info.Name] = entry
+}
+
+// SetHistoryLimits bounds the transcript history of new rooms.
+func (h *Hub) SetHistoryLimits(limits HistoryLimits) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.historyLimits = limits
+}
+
+// SetStore makes new rooms write their session, speakers and final segments to s.
+func (h *Hub) SetStore(s store.Store) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.store = s
+}
+
+// ErrRecordingActive is returned for audio downloads while the room still records.
+var ErrRecordingActive = errors.New("audio recording is still in progress")
+
+// EnableRecording allows sessions to store audio and recordings in dir,
+// see Options.AudioConsent and Options.Record.
+func (h *Hub) EnableRecording(dir string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recordingsDir = dir
+}
+
+// Providers returns all known providers sorted by name.
+func (h *Hub) Providers() []ProviderInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := make([]ProviderInfo, 0, len(h.providers))
+	for _, entry := range h.providers {
+		list = append(list, entry.info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
 }
 
 // CreateSession generates a secure ID and initializes the room.
-func (h *Hub) CreateSession(providerName string) (string, error) {
+// providerNames is the failover chain: the first provider is used until it fails,
+// then the room switches to the next one.
+func (h *Hub) CreateSession(providerNames []string, opts transcription.Options) (string, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	factory, ok := h.factories[providerName]
-	if !ok {
-		return "", fmt.Errorf("provider %s not found", providerName)
+	if len(providerNames) == 0 {
+		return "", errors.New("no provider given")
+	}
+
+	vocabulary, err := transcription.NormalizeVocabulary(opts.Vocabulary)
+	if err != nil {
+		return "", err
+	}
+	opts.Vocabulary = vocabulary
+	opts = opts.WithDefaults()
+
+	opts.ProviderOptions, err = h.resolveProviderOptions(providerNames, opts.ProviderOptions)
+	if err != nil {
+		return "", err
+	}
+	format, err := h.chainFormat(providerNames)
+	if err != nil {
+		return "", err
+	}
+	if opts.Record && !opts.AudioConsent {
+		return "", errors.New("recording a session requires audio consent")
+	}
+	if opts.AudioConsent && h.recordingsDir == "" {
+		return "", errors.New("recording is not enabled on this server")
 	}
 
 	id := generateID()
-	room := NewRoom(id, factory())
+	chain := make([]providerSlot, 0, len(providerNames))
+	for _, name := range providerNames {
+		slot, err := h.newProviderSlot(name, opts)
+		if err == nil && opts.Record {
+			slot, err = h.record(id, slot)
+		}
+		if err != nil {
+			for _, created := range chain {
+				created.service.Close()
+			}
+			return "", err
+		}
+		chain = append(chain, slot)
+	}
+
+	room := NewRoom(id, chain[0].service, opts)
+	room.provider = chain[0].name
+	room.format = format
+	room.history.limits = h.historyLimits
+	if h.store != nil {
+		err := h.store.CreateSession(store.Session{
+			ID:       id,
+			Provider: chain[0].name,
+			Options:  opts,
+			Created:  room.created,
+		})
+		if err != nil {
+			// Captions matter more than the archive, the room runs anyway
+			log.Printf("Room %s: Storing session failed: %v", id, err)
+		} else {
+			room.store = h.store
+		}
+	}
+	room.fallbacks = chain[1:]
+	if opts.AudioConsent {
+		if err := os.MkdirAll(h.recordingsDir, 0o750); err != nil {
+			for _, created := range chain {
+				created.service.Close()
+			}
+			return "", fmt.Errorf("audio recording: %w", err)
+		}
+		room.audio = newAudioRecording(filepath.Join(h.recordingsDir, id))
+	}
 
 	// Start the room loop immediately so it's ready for connections
 	go room.Run(func() {
@@ -64,6 +208,112 @@ func (h *Hub) CreateSession(providerName string) (string, error) {
 	return id, nil
 }
 
+// resolveProviderOptions merges the requested settings with the defaults of every
+// provider in the chain. The result holds the effective settings of the session.
+// Callers must hold h.mu.
+func (h *Hub) resolveProviderOptions(providerNames []string, requested map[string]transcription.ProviderParams) (map[string]transcription.ProviderParams, error) {
+	for name := range requested {
+		if !contains(providerNames, name) {
+			return nil, fmt.Errorf("options given for provider %s, which is not part of the session", name)
+		}
+	}
+
+	resolved := make(map[string]transcription.ProviderParams)
+	for _, name := range providerNames {
+		entry, ok := h.providers[name]
+		if !ok || len(entry.info.Options) == 0 {
+			if len(requested[name]) > 0 {
+				return nil, fmt.Errorf("provider %s has no options", name)
+			}
+			continue
+		}
+		params, err := transcription.MergeParams(entry.info.Options, requested[name])
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		resolved[name] = params
+	}
+	if len(resolved) == 0 {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// chainFormat returns the audio format of the failover chain. The host picks its
+// capture pipeline once, so all providers that declare a format must agree on it.
+// Providers without a format (mock, replay) accept any audio. Callers must hold h.mu.
+func (h *Hub) chainFormat(providerNames []string) (transcription.AudioFormat, error) {
+	var format transcription.AudioFormat
+	first := ""
+	for _, name := range providerNames {
+		declared := h.providers[name].info.Capabilities.AudioFormat
+		if declared == "" {
+			continue
+		}
+		if format != "" && declared != format {
+			return "", fmt.Errorf("providers %s (%s) and %s (%s) expect different audio formats and cannot share a failover chain",
+				first, format, name, declared)
+		}
+		if format == "" {
+			format, first = declared, name
+		}
+	}
+	return format, nil
+}
+
+// newProviderSlot creates a service for the session. Callers must hold h.mu.
+func (h *Hub) newProviderSlot(name string, opts transcription.Options) (providerSlot, error) {
+	entry, ok := h.providers[name]
+	if !ok {
+		return providerSlot{}, fmt.Errorf("provider %s not found", name)
+	}
+	if entry.factory == nil {
+		return providerSlot{}, fmt.Errorf("provider %s is not configured", name)
+	}
+	if opts.AutoDetect && !entry.info.Capabilities.AutoDetect {
+		return providerSlot{}, fmt.Errorf("provider %s does not support language auto detection", name)
+	}
+
+	service, err := entry.factory(opts)
+	if err != nil {
+		return providerSlot{}, fmt.Errorf("provider %s: %w", name, err)
+	}
+
+	return providerSlot{
+		name:    name,
+		service: service,
+		format:  entry.info.Capabilities.AudioFormat,
+	}, nil
+}
+
+// record wraps the service of the slot so the session is written to
+// "<room>-<provider>.jsonl" in the recordings directory. Callers must hold h.mu.
+func (h *Hub) record(roomID string, slot providerSlot) (providerSlot, error) {
+	if err := os.MkdirAll(h.recordingsDir, 0o750); err != nil {
+		slot.service.Close()
+		return providerSlot{}, fmt.Errorf("recording: %w", err)
+	}
+	path := filepath.Join(h.recordingsDir, roomID+"-"+slot.name+replay.Extension)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		slot.service.Close()
+		return providerSlot{}, fmt.Errorf("recording: %w", err)
+	}
+
+	log.Printf("Room %s: Recording provider %s to %s", roomID, slot.name, path)
+	slot.service = replay.Record(slot.service, f, slot.name, slot.format)
+	return slot, nil
+}
+
 func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	roomID := r.URL.Query().Get("room")
 	role := r.URL.Query().Get("role") // "host" or empty
@@ -112,6 +362,79 @@ func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	go client.readPump()
 }
 
+// AudioFile returns the path of a stored audio part of a session, counted from 1.
+// Files stay on disk after the room closed; they can be downloaded once it stopped recording.
+func (h *Hub) AudioFile(roomID string, part int) (string, error) {
+	h.mu.RLock()
+	dir, room := h.recordingsDir, h.rooms[roomID]
+	h.mu.RUnlock()
+
+	if dir == "" {
+		return "", errors.New("recording is not enabled on this server")
+	}
+	if !isRoomID(roomID) || part < 1 {
+		return "", fmt.Errorf("invalid audio part %s/%d", roomID, part)
+	}
+	if room != nil && room.AudioRecording() {
+		return "", ErrRecordingActive
+	}
+
+	base := partBase(filepath.Join(dir, roomID), part)
+	for _, ext := range audiofile.Extensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext, nil
+		}
+	}
+	return "", fmt.Errorf("no audio part %d stored for room %s", part, roomID)
+}
+
+// SessionInfo returns the metadata of a running session.
+func (h *Hub) SessionInfo(id string) (SessionInfo, error) {
+	room := h.getRoom(id)
+	if room == nil {
+		return SessionInfo{}, fmt.Errorf("room %s not found", id)
+	}
+	return room.Info(), nil
+}
+
+// Transcript returns the final segments of a session for export. Stored sessions are
+// complete and outlive the room; without a store the history of the running room is used.
+func (h *Hub) Transcript(id string) (export.Transcript, error) {
+	h.mu.RLock()
+	s, room := h.store, h.rooms[id]
+	h.mu.RUnlock()
+
+	if s != nil {
+		t, err := storedTranscript(s, id)
+		if err == nil {
+			return t, nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return export.Transcript{}, err
+		}
+	}
+	if room == nil {
+		return export.Transcript{}, fmt.Errorf("room %s not found", id)
+	}
+	return room.Transcript(), nil
+}
+
+func storedTranscript(s store.Store, id string) (export.Transcript, error) {
+	session, err := s.Session(id)
+	if err != nil {
+		return export.Transcript{}, err
+	}
+	speakers, err := s.Speakers(id)
+	if err != nil {
+		return export.Transcript{}, err
+	}
+	segments, err := s.Segments(id)
+	if err != nil {
+		return export.Transcript{}, err
+	}
+	return export.Transcript{Session: session, Speakers: speakers, Segments: segments}, nil
+}
+
 func (h *Hub) getRoom(id string) *Room {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -131,6 +454,15 @@ func (h *Hub) CloseSession(id string) error {
 	return nil
 }
 
+// isRoomID reports whether id has the form of generateID, so it is safe in file names.
+func isRoomID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
 // generateID creates a random 16-byte hex string (UUID-like)
 func generateID() string {
 	b := make([]byte, 16)
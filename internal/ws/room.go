@@ -2,10 +2,16 @@ package ws
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/joshuabeny1999/tolka/internal/export"
+	"github.com/joshuabeny1999/tolka/internal/store"
 	"github.com/joshuabeny1999/tolka/internal/transcription"
 )
 
@@ -14,10 +20,7 @@ import (
 const idleTimeout = 2 * time.Minute
 
 // SpeakerData stores Name and Position (0-360 Grad)
-type SpeakerData struct {
-	Name     string `json:"name"`
-	Position int    `json:"position"` // 0 = Oben (Standard), 90 = Rechts, etc.
-}
+type SpeakerData = store.Speaker
 
 // WSMessage is a Wrapper for all WebSocket Messages
 type WSMessage struct {
@@ -33,7 +36,19 @@ type Room struct {
 	register    chan *Client
 	unregister  chan *Client
 	audioIngest chan []byte
+	edits       chan SegmentEdit
+	vocabulary  chan []string
 	service     transcription.Service
+	provider    string                    // Name of the active provider
+	format      transcription.AudioFormat // Audio format of the failover chain
+	fallbacks   []providerSlot            // Remaining failover chain
+	options     transcription.Options
+	utterances  utteranceTracker
+	streamTime  streamTime // Only used by Run
+	history     transcriptHistory
+	audio       *audioRecording // nil without audio consent
+	store       store.Store     // nil without persistence
+	created     time.Time
 
 	// Timer to handle inactivity
 	idleTimer *time.Timer
@@ -45,8 +60,83 @@ type Room struct {
 	hasHost bool
 }
 
-func NewRoom(id string, service transcription.Service) *Room {
-	ctx, cancel := context.WithCancel(context.Background())
+// streamTime keeps the result timings of a session continuous across provider
+// connections, each of which counts from zero.
+type streamTime struct {
+	offset float64 // Added to the timings of the current connection
+	end    float64 // End of the latest result, after the offset
+}
+
+// Shift moves the result behind the previous connections.
+func (s *streamTime) Shift(result *transcription.TranscriptResult) {
+	result.Shift(s.offset)
+	s.end = max(s.end, result.End)
+}
+
+// ShiftTime moves a stream position, zero stays unknown.
+func (s *streamTime) ShiftTime(seconds float64) float64 {
+	if seconds == 0 {
+		return 0
+	}
+	return seconds + s.offset
+}
+
+// NextConnection starts a connection whose timings continue after the last result.
+func (s *streamTime) NextConnection() {
+	s.offset = s.end
+}
+
+// providerSlot is a provider of the session's failover chain.
+type providerSlot struct {
+	name    string
+	service transcription.Service
+	format  transcription.AudioFormat
+}
+
+// ProviderSwitchPayload is sent with "provider_switch" messages.
+// To is empty when no provider is left, captions have stopped then.
+type ProviderSwitchPayload struct {
+	From        string                    `json:"from"`
+	To          string                    `json:"to"`
+	Reason      string                    `json:"reason"`
+	AudioFormat transcription.AudioFormat `json:"audio_format,omitempty"`
+}
+
+// ProviderStatusPayload is sent with "provider_status" messages
+type ProviderStatusPayload struct {
+	Provider string `json:"provider"`
+	transcription.Status
+}
+
+// SpeechActivityPayload is sent with "speech_start" and "speech_end" messages
+type SpeechActivityPayload struct {
+	Provider string  `json:"provider"`
+	Speaker  string  `json:"speaker,omitempty"`
+	Time     float64 `json:"time,omitempty"`
+}
+
+// SessionInfo is the metadata of a session, served by GET /api/session
+type SessionInfo struct {
+	RoomID string `json:"roomId"`
+	// Provider is the active provider, Fallbacks the remaining failover chain.
+	Provider  string                `json:"provider"`
+	Fallbacks []string              `json:"fallbacks,omitempty"`
+	Options   transcription.Options `json:"options"`
+}
+
+// statusChan returns the status channel of reconnecting services, nil otherwise.
+// Receiving from a nil channel blocks forever, so the select case stays inactive.
+func statusChan(service transcription.Service) <-chan transcription.Status {
+	if reporter, ok := service.(transcription.StatusReporter); ok {
+		return reporter.StatusChan()
+	}
+	return nil
+}
+
+func NewRoom(id string, service transcription.Service, opts transcription.Options) *Room {
+	// Provider logs carry the room ID
+	ctx := transcription.WithLogger(context.Background(), slog.Default().With("room", id))
+	ctx, cancel := context.WithCancel(ctx)
 	return &Room{
 		ID:          id,
 		clients:     make(map[*Client]bool),
@@ -55,7 +145,12 @@ func NewRoom(id string, service transcription.Service) *Room {
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		audioIngest: make(chan []byte),
+		edits:       make(chan SegmentEdit),
+		vocabulary:  make(chan []string),
 		service:     service,
+		options:     opts,
+		history:     transcriptHistory{limits: DefaultHistoryLimits},
+		created:     time.Now(),
 
 		// Start timer immediately. If no one joins within idleTimeout, room dies.
 		idleTimer: time.NewTimer(idleTimeout),
@@ -90,14 +185,28 @@ func (r *Room) Run(cleanupFunc func()) {
 	defer func() {
 		// Stop the timer to prevent leaks if function exits for other reasons
 		r.idleTimer.Stop()
+		// Finish the audio file before the room disappears, downloads are possible from then on
+		if r.audio != nil {
+			r.audio.Close()
+		}
+		if r.store != nil {
+			if err := r.store.CloseSession(r.ID, time.Now()); err != nil {
+				log.Printf("Room %s: Storing session end failed: %v", r.ID, err)
+			}
+		}
 		cleanupFunc()
 		r.cancel()
-		r.service.Close()
+		r.currentService().Close()
+		for _, slot := range r.fallbacks {
+			slot.service.Close()
+		}
 	}()
 
-	if err := r.service.Connect(r.ctx); err != nil {
+	if err := r.service.Connect(r.ctx, r.options); err != nil {
 		log.Printf("Room %s: Service connect failed: %v", r.ID, err)
-		return
+		if !r.failover(err) {
+			return
+		}
 	}
 
 	go r.processAudio()
@@ -127,6 +236,10 @@ func (r *Room) Run(cleanupFunc func()) {
 				Payload: currentSpeakers,
 			}
 			client.send <- initMsg
+			client.send <- WSMessage{
+				Type:    "history",
+				Payload: HistoryPayload{Segments: r.history.Segments(time.Now())},
+			}
 
 		case client := <-r.unregister:
 			if _, ok := r.clients[client]; ok {
@@ -134,6 +247,12 @@ func (r *Room) Run(cleanupFunc func()) {
 				close(client.send)
 				if client.isHost {
 					r.ReleaseHost()
+					// Host stopped streaming, the last utterance should not wait for more audio
+					if flusher, ok := r.currentService().(transcription.Flusher); ok {
+						if err := flusher.Flush(); err != nil {
+							log.Printf("Room %s: Flush failed: %v", r.ID, err)
+						}
+					}
 				}
 
 				if len(r.clients) == 0 {
@@ -144,19 +263,67 @@ func (r *Room) Run(cleanupFunc func()) {
 
 		case result, ok := <-r.service.ResultChan():
 			if !ok {
+				if r.failover(errors.New("result channel closed")) {
+					continue
+				}
 				return
 			}
+			r.utterances.Assign(&result)
+			r.streamTime.Shift(&result)
+			if result.Language == "" {
+				result.Language = r.options.Language
+			}
+			now := time.Now()
+			r.history.Add(result, now)
+			if r.store != nil && !result.IsPartial {
+				segment := store.Segment{TranscriptResult: result, ReceivedAt: now}
+				if err := r.store.AppendSegment(r.ID, segment); err != nil {
+					log.Printf("Room %s: Storing segment failed: %v", r.ID, err)
+				}
+			}
 			msg := WSMessage{
 				Type:    "transcript",
 				Payload: result,
 			}
 			r.broadcastToClients(msg)
 
-		case _, ok := <-r.service.ErrorChan():
+		case err, ok := <-r.service.ErrorChan():
 			if !ok {
+				if r.failover(errors.New("error channel closed")) {
+					continue
+				}
+				return
+			}
+			log.Printf("Room %s: Provider %s error: %v", r.ID, r.provider, err)
+			if !r.failover(err) {
 				return
 			}
 
+		case edit := <-r.edits:
+			r.applyEdit(edit)
+
+		case vocabulary := <-r.vocabulary:
+			r.applyVocabulary(vocabulary)
+
+		case status := <-statusChan(r.service):
+			r.broadcastToClients(WSMessage{
+				Type: "provider_status",
+				Payload: ProviderStatusPayload{
+					Provider: r.provider,
+					Status:   status,
+				},
+			})
+
+		case event := <-transcription.ActivityChan(r.service):
+			r.broadcastToClients(WSMessage{
+				Type: event.Type,
+				Payload: SpeechActivityPayload{
+					Provider: r.provider,
+					Speaker:  event.Speaker,
+					Time:     r.streamTime.ShiftTime(event.Time),
+				},
+			})
+
 		case <-r.idleTimer.C:
 			log.Printf("Room %s idle timeout reached. Shutting down.", r.ID)
 			return
@@ -188,6 +355,12 @@ func (r *Room) UpdateSpeaker(id string, name string, position int) {
 	r.speakers[id] = data
 	r.mu.Unlock()
 
+	if r.store != nil {
+		if err := r.store.SaveSpeaker(r.ID, id, data); err != nil {
+			log.Printf("Room %s: Storing speaker failed: %v", r.ID, err)
+		}
+	}
+
 	// Broadcast to ALL clients for sync
 	msg := WSMessage{
 		Type:    "speaker_update",
@@ -196,6 +369,61 @@ func (r *Room) UpdateSpeaker(id string, name string, position int) {
 	r.broadcastToClients(msg)
 }
 
+// EditSegment hands a host correction of a final segment to the room loop.
+// It is called from the host's read goroutine, the loop owns the clients.
+func (r *Room) EditSegment(edit SegmentEdit) {
+	edit.Time = time.Now()
+	edit.Text = strings.TrimSpace(edit.Text)
+	if err := edit.Validate(); err != nil {
+		log.Printf("Room %s: Rejected edit: %v", r.ID, err)
+		return
+	}
+	select {
+	case r.edits <- edit:
+	case <-r.ctx.Done():
+	}
+}
+
+// applyEdit applies a correction to the history, stores it and sends the edit with
+// its new revision to all clients. Segments the history already dropped are only
+// edited in the store. Runs in Run.
+func (r *Room) applyEdit(edit SegmentEdit) {
+	edited, err := r.history.Edit(edit)
+	if err != nil && r.store != nil {
+		edited, err = r.storedEdit(edit)
+	}
+	if err != nil {
+		log.Printf("Room %s: Rejected edit: %v", r.ID, err)
+		return
+	}
+
+	if r.store != nil {
+		if err := r.store.AppendEdit(r.ID, edited); err != nil {
+			log.Printf("Room %s: Storing edit failed: %v", r.ID, err)
+		}
+	}
+
+	r.broadcastToClients(WSMessage{
+		Type:    "segment_edit",
+		Payload: edited,
+	})
+}
+
+// storedEdit returns the edit with the revision following the stored segment.
+func (r *Room) storedEdit(edit SegmentEdit) (SegmentEdit, error) {
+	segments, err := r.store.Segments(r.ID)
+	if err != nil {
+		return edit, err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i].UtteranceID == edit.UtteranceID {
+			edit.Revision = segments[i].Revision + 1
+			return edit, nil
+		}
+	}
+	return edit, fmt.Errorf("no segment %s stored", edit.UtteranceID)
+}
+
 func (r *Room) SendCurrentSpeakers(client *Client) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -216,20 +444,176 @@ func (r *Room) SendCurrentSpeakers(client *Client) {
 	// Non-blocking send versuch
 	select {
 	case client.send <- msg:
-		log.Printf("Sent. speaker_update to %s")
+		log.Printf("Sent. speaker_update to %s", client.conn.RemoteAddr().String())
 	default:
-		log.Printf("Coulnd't send. speaker_update to %s")
+		log.Printf("Coulnd't send. speaker_update to %s", client.conn.RemoteAddr().String())
 		// Client buffer voll oder weg
 	}
 }
 
+// VocabularyPayload is sent with "vocabulary_update" messages
+type VocabularyPayload struct {
+	Vocabulary []string `json:"vocabulary"`
+}
+
+// UpdateVocabulary hands a new session vocabulary to the room loop.
+// It is called from the host's read goroutine, the loop owns the clients and options.
+func (r *Room) UpdateVocabulary(phrases []string) {
+	vocabulary, err := transcription.NormalizeVocabulary(phrases)
+	if err != nil {
+		log.Printf("Room %s: Rejected vocabulary: %v", r.ID, err)
+		return
+	}
+	select {
+	case r.vocabulary <- vocabulary:
+	case <-r.ctx.Done():
+	}
+}
+
+// applyVocabulary replaces the session vocabulary and forwards it to the provider
+// if it supports live updates. Otherwise the vocabulary applies on the next connect.
+// Runs in Run.
+func (r *Room) applyVocabulary(vocabulary []string) {
+	r.mu.Lock()
+	r.options.Vocabulary = vocabulary
+	r.mu.Unlock()
+
+	if updater, ok := r.currentService().(transcription.VocabularyUpdater); ok {
+		if err := updater.UpdateVocabulary(vocabulary); err != nil {
+			log.Printf("Room %s: UpdateVocabulary error: %v", r.ID, err)
+		}
+	} else {
+		log.Printf("Room %s: Provider does not support live vocabulary updates", r.ID)
+	}
+
+	msg := WSMessage{
+		Type:    "vocabulary_update",
+		Payload: VocabularyPayload{Vocabulary: vocabulary},
+	}
+	r.broadcastToClients(msg)
+}
+
+func (r *Room) SendVocabulary(client *Client) {
+	r.mu.Lock()
+	vocabulary := append([]string{}, r.options.Vocabulary...)
+	r.mu.Unlock()
+
+	msg := WSMessage{
+		Type:    "vocabulary_update",
+		Payload: VocabularyPayload{Vocabulary: vocabulary},
+	}
+
+	select {
+	case client.send <- msg:
+	default:
+	}
+}
+
+// Info returns the session metadata including the effective provider options.
+func (r *Room) Info() SessionInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info := SessionInfo{
+		RoomID:   r.ID,
+		Provider: r.provider,
+		Options:  r.options,
+	}
+	for _, slot := range r.fallbacks {
+		info.Fallbacks = append(info.Fallbacks, slot.name)
+	}
+	return info
+}
+
+// Transcript returns the session with its speakers and the final segments of the history.
+func (r *Room) Transcript() export.Transcript {
+	r.mu.Lock()
+	t := export.Transcript{
+		Session:  store.Session{ID: r.ID, Provider: r.provider, Options: r.options, Created: r.created},
+		Speakers: make(map[string]SpeakerData, len(r.speakers)),
+	}
+	for id, speaker := range r.speakers {
+		t.Speakers[id] = speaker
+	}
+	r.mu.Unlock()
+
+	t.Segments = r.history.Segments(time.Now())
+	return t
+}
+
+// AudioRecording reports whether the host's audio of the room is being stored.
+func (r *Room) AudioRecording() bool {
+	return r.audio != nil && r.audio.Active()
+}
+
+func (r *Room) currentService() transcription.Service {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.service
+}
+
+// failover replaces the active service with the next provider of the chain
+// that connects successfully. Clients stay connected and are informed with a
+// "provider_switch" message. Returns false if the chain is exhausted, clients
+// then get a last "provider_switch" without target and the room closes.
+func (r *Room) failover(reason error) bool {
+	for len(r.fallbacks) > 0 {
+		next := r.fallbacks[0]
+
+		r.mu.Lock()
+		previous, from := r.service, r.provider
+		// The audio format stays, CreateSession only accepts chains with one format
+		r.service, r.provider = next.service, next.name
+		r.fallbacks = r.fallbacks[1:]
+		r.mu.Unlock()
+
+		previous.Close()
+		r.utterances.Reset()
+		r.streamTime.NextConnection()
+
+		log.Printf("Room %s: Switching provider %s -> %s (%v)", r.ID, from, next.name, reason)
+		r.broadcastToClients(WSMessage{
+			Type: "provider_switch",
+			Payload: ProviderSwitchPayload{
+				From:        from,
+				To:          next.name,
+				Reason:      reason.Error(),
+				AudioFormat: next.format,
+			},
+		})
+
+		err := next.service.Connect(r.ctx, r.options)
+		if err == nil {
+			return true
+		}
+		log.Printf("Room %s: Service connect failed: %v", r.ID, err)
+		reason = err
+	}
+
+	log.Printf("Room %s: No provider left after %s (%v)", r.ID, r.provider, reason)
+	r.broadcastToClients(WSMessage{
+		Type: "provider_switch",
+		Payload: ProviderSwitchPayload{
+			From:   r.provider,
+			Reason: reason.Error(),
+		},
+	})
+	return false
+}
+
 func (r *Room) processAudio() {
 	for {
 		select {
 		case <-r.ctx.Done():
 			return
 		case data := <-r.audioIngest:
-			if err := r.service.SendAudio(data); err != nil {
+			if r.audio != nil {
+				r.mu.Lock()
+				format := r.format
+				r.mu.Unlock()
+				r.audio.Write(format, data)
+			}
+			if err := r.currentService().SendAudio(data); err != nil {
 				log.Printf("Room %s: SendAudio error: %v", r.ID, err)
 			}
 		}
@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"fmt"
+
+	"github.com/joshuabeny1999/tolka/internal/transcription"
+)
+
+// maxSegments bounds the segment numbers, they come from external workers.
+const maxSegments = 32
+
+// utteranceTracker assigns stable utterance IDs to provider results.
+// All providers stream one utterance at a time: partials followed by a final.
+// The final closes the utterance, the next result opens a new one.
+// Results split by speaker carry a segment number; each segment has its own utterance.
+// The finals of a split result arrive in segment order, segments above the last
+// final were dropped by the provider and are closed as well.
+type utteranceTracker struct {
+	seq    int
+	open   []openUtterance // Indexed by segment
+	finals int             // Segments of the current final group so far, 0 if none
+}
+
+type openUtterance struct {
+	id       string
+	revision int
+}
+
+// Assign stamps the result with the ID and revision of the open utterance.
+func (t *utteranceTracker) Assign(result *transcription.TranscriptResult) {
+	// Kommt von externen Workern
+	result.Segment = min(max(result.Segment, 0), maxSegments-1)
+
+	if t.finals > 0 && (result.IsPartial || result.Segment < t.finals) {
+		// Die finale Gruppe ist abgeschlossen
+		t.open = t.open[:min(len(t.open), t.finals)]
+		t.finals = 0
+	}
+
+	for len(t.open) <= result.Segment {
+		t.open = append(t.open, openUtterance{})
+	}
+	u := &t.open[result.Segment]
+
+	if u.id == "" {
+		t.seq++
+		u.id = fmt.Sprintf("u%d", t.seq)
+		u.revision = 0
+	}
+
+	u.revision++
+	result.UtteranceID = u.id
+	result.Revision = u.revision
+
+	if !result.IsPartial {
+		*u = openUtterance{}
+		t.finals = result.Segment + 1
+	}
+}
+
+// Reset closes the open utterances, e.g. when the provider changes.
+func (t *utteranceTracker) Reset() {
+	t.open = nil
+	t.finals = 0
+}
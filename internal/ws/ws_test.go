@@ -3,14 +3,20 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/joshuabeny1999/tolka/internal/store"
 	"github.com/joshuabeny1999/tolka/internal/transcription"
+	"github.com/joshuabeny1999/tolka/internal/transcription/mock"
 )
 
 // 0. Minimal MockService
@@ -19,24 +25,84 @@ type MockService struct {
 	errorChan  chan error
 }
 
-func (m *MockService) Connect(ctx context.Context) error                 { return nil }
+func (m *MockService) Connect(ctx context.Context, opts transcription.Options) error {
+	return nil
+}
 func (m *MockService) SendAudio(data []byte) error                       { return nil }
 func (m *MockService) ResultChan() <-chan transcription.TranscriptResult { return m.resultChan }
 func (m *MockService) ErrorChan() <-chan error                           { return m.errorChan }
 func (m *MockService) Close() error                                      { return nil }
 
+// newTestRoom registers service as provider "test", opens a session on it
+// and joins it as a viewer.
+func newTestRoom(t *testing.T, service transcription.Service) (*Hub, string, *websocket.Conn) {
+	t.Helper()
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return service, nil
+	})
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	t.Cleanup(func() { hub.CloseSession(roomID) })
+	return hub, roomID, joinRoom(t, hub, roomID, "")
+}
+
+// dialRoom connects a client with the given role ("" for viewers) to a room.
+// Server and connection are closed when the test ends.
+func dialRoom(t *testing.T, hub *Hub, roomID, role string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(hub)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?room=" + roomID
+	if role != "" {
+		url += "&role=" + role
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect to room %s: %v", roomID, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// joinRoom is dialRoom past the speaker_update and history every client gets on join.
+func joinRoom(t *testing.T, hub *Hub, roomID, role string) *websocket.Conn {
+	t.Helper()
+	conn := dialRoom(t, hub, roomID, role)
+	readType(t, conn, "speaker_update")
+	readType(t, conn, "history")
+	return conn
+}
+
+// readType reads the next message and fails unless it has the expected type.
+func readType(t *testing.T, conn *websocket.Conn, expected string) WSMessage {
+	t.Helper()
+	var msg WSMessage
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read %s: %v", expected, err)
+	}
+	if msg.Type != expected {
+		t.Fatalf("Expected %s, got %s", expected, msg.Type)
+	}
+	return msg
+}
+
 func TestCompleteSessionFlow(t *testing.T) {
 	// 1. Setup Hub
 	hub := NewHub()
-	hub.RegisterProvider("test", func() transcription.Service {
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
 		return &MockService{
 			resultChan: make(chan transcription.TranscriptResult),
 			errorChan:  make(chan error),
-		}
+		}, nil
 	})
 
 	// 2. Create Session manually
-	roomID, err := hub.CreateSession("test")
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{})
 	if err != nil {
 		t.Fatalf("Failed to create session: %v", err)
 	}
@@ -68,6 +134,9 @@ func TestCompleteSessionFlow(t *testing.T) {
 	if err := hostConn.ReadJSON(&initMsg); err != nil {
 		t.Fatalf("Host failed to read initial sync: %v", err)
 	}
+	if err := hostConn.ReadJSON(&initMsg); err != nil || initMsg.Type != "history" {
+		t.Fatalf("Host failed to read history: %v %v", initMsg.Type, err)
+	}
 
 	// 6. Connect Second HOST (Should Fail)
 	_, resp, err := websocket.DefaultDialer.Dial(hostURL, nil)
@@ -195,3 +264,792 @@ func TestIDGeneration(t *testing.T) {
 		t.Error("IDs should be unique")
 	}
 }
+
+func TestUtteranceTracker(t *testing.T) {
+	var tracker utteranceTracker
+
+	results := []transcription.TranscriptResult{
+		{Text: "Hallo", IsPartial: true},
+		{Text: "Hallo zusammen", IsPartial: true},
+		{Text: "Hallo zusammen.", IsPartial: false},
+		{Text: "Guten", IsPartial: true},
+	}
+	for i := range results {
+		tracker.Assign(&results[i])
+	}
+
+	for i := 0; i < 3; i++ {
+		if results[i].UtteranceID != "u1" {
+			t.Errorf("Result %d: expected utterance 'u1', got '%s'", i, results[i].UtteranceID)
+		}
+		if results[i].Revision != i+1 {
+			t.Errorf("Result %d: expected revision %d, got %d", i, i+1, results[i].Revision)
+		}
+	}
+
+	if results[3].UtteranceID != "u2" || results[3].Revision != 1 {
+		t.Errorf("Expected new utterance 'u2' rev 1 after final, got '%s' rev %d", results[3].UtteranceID, results[3].Revision)
+	}
+}
+
+func TestUtteranceTracker_Segments(t *testing.T) {
+	var tracker utteranceTracker
+
+	// Ein Ergebnis, aufgeteilt auf zwei Sprecher: erst als Partials, dann final
+	results := []transcription.TranscriptResult{
+		{Text: "Kommst du", IsPartial: true},
+		{Text: "Ja", IsPartial: true, Segment: 1},
+		{Text: "Kommst du?", IsPartial: false},
+		{Text: "Ja.", IsPartial: false, Segment: 1},
+		{Text: "Gut", IsPartial: true},
+	}
+	for i := range results {
+		tracker.Assign(&results[i])
+	}
+
+	expected := []struct {
+		id       string
+		revision int
+	}{{"u1", 1}, {"u2", 1}, {"u1", 2}, {"u2", 2}, {"u3", 1}}
+	for i, want := range expected {
+		if results[i].UtteranceID != want.id || results[i].Revision != want.revision {
+			t.Errorf("Result %d: expected %s rev %d, got %s rev %d", i, want.id, want.revision, results[i].UtteranceID, results[i].Revision)
+		}
+	}
+}
+
+func TestUtteranceTracker_DroppedSegments(t *testing.T) {
+	var tracker utteranceTracker
+
+	// Die Partials haben drei Sprecher, das finale Ergebnis nur noch zwei
+	results := []transcription.TranscriptResult{
+		{Text: "Kommst", IsPartial: true},
+		{Text: "du", IsPartial: true, Segment: 1},
+		{Text: "mit", IsPartial: true, Segment: 2},
+		{Text: "Kommst", IsPartial: false},
+		{Text: "du mit?", IsPartial: false, Segment: 1},
+		{Text: "Ja", IsPartial: true, Segment: 2},
+		{Text: "Riesig", IsPartial: true, Segment: 1e9},
+	}
+	for i := range results {
+		tracker.Assign(&results[i])
+	}
+
+	if results[5].UtteranceID != "u4" || results[5].Revision != 1 {
+		t.Errorf("Expected new utterance 'u4' rev 1 for dropped segment, got '%s' rev %d", results[5].UtteranceID, results[5].Revision)
+	}
+	if results[6].Segment != maxSegments-1 || len(tracker.open) != maxSegments {
+		t.Errorf("Expected segment clamped to %d, got %d (%d open)", maxSegments-1, results[6].Segment, len(tracker.open))
+	}
+}
+
+func TestCreateSession_UnsupportedLanguage(t *testing.T) {
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		if err := opts.Validate([]string{"de-CH", "en-US"}); err != nil {
+			return nil, err
+		}
+		return &MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		}, nil
+	})
+
+	_, err := hub.CreateSession([]string{"test"}, transcription.Options{Language: "fr-FR"})
+	if !errors.Is(err, transcription.ErrUnsupportedLanguage) {
+		t.Errorf("Expected ErrUnsupportedLanguage, got %v", err)
+	}
+
+	_, err = hub.CreateSession([]string{"test"}, transcription.Options{Language: "de-ch", Languages: []string{"EN-us"}})
+	if err != nil {
+		t.Errorf("Expected case-insensitive match, got %v", err)
+	}
+}
+
+func TestHub_Providers(t *testing.T) {
+	hub := NewHub()
+	factory := func(opts transcription.Options) (transcription.Service, error) {
+		return &MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		}, nil
+	}
+	hub.RegisterProvider(ProviderInfo{Name: "remote", Configured: false}, factory)
+	hub.RegisterProvider(ProviderInfo{Name: "local", Configured: true}, factory)
+
+	providers := hub.Providers()
+	if len(providers) != 2 || providers[0].Name != "local" || providers[1].Name != "remote" {
+		t.Fatalf("Expected providers [local remote], got %+v", providers)
+	}
+	if providers[1].Configured {
+		t.Error("Expected 'remote' to be unconfigured")
+	}
+
+	if _, err := hub.CreateSession([]string{"remote"}, transcription.Options{}); err == nil {
+		t.Error("Expected error creating session with unconfigured provider")
+	}
+	if _, err := hub.CreateSession([]string{"local"}, transcription.Options{AutoDetect: true}); err == nil {
+		t.Error("Expected error requesting auto detection from provider without capability")
+	}
+}
+
+func TestCreateSession_ProviderOptions(t *testing.T) {
+	hub := NewHub()
+	var received transcription.Options
+	hub.RegisterProvider(ProviderInfo{
+		Name:       "tunable",
+		Configured: true,
+		Options:    map[string]string{"endpointing": "500", "model": "nova-3"},
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		received = opts
+		return &MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		}, nil
+	})
+
+	id, err := hub.CreateSession([]string{"tunable"}, transcription.Options{
+		ProviderOptions: map[string]transcription.ProviderParams{"tunable": {"endpointing": "300"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	defer hub.CloseSession(id)
+
+	want := transcription.ProviderParams{"endpointing": "300", "model": "nova-3"}
+	if got := received.ProviderOptions["tunable"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected merged options %v, got %v", want, got)
+	}
+
+	info, err := hub.SessionInfo(id)
+	if err != nil {
+		t.Fatalf("SessionInfo failed: %v", err)
+	}
+	if info.Provider != "tunable" || !reflect.DeepEqual(info.Options.ProviderOptions["tunable"], want) {
+		t.Errorf("Unexpected session info: %+v", info)
+	}
+
+	invalid := []map[string]transcription.ProviderParams{
+		{"tunable": {"unknown": "1"}},
+		{"other": {"endpointing": "300"}},
+	}
+	for _, opts := range invalid {
+		if _, err := hub.CreateSession([]string{"tunable"}, transcription.Options{ProviderOptions: opts}); err == nil {
+			t.Errorf("Expected error for options %v", opts)
+		}
+	}
+}
+
+// vocabularyService records live vocabulary updates
+type vocabularyService struct {
+	MockService
+	updates chan []string
+}
+
+func (v *vocabularyService) UpdateVocabulary(phrases []string) error {
+	v.updates <- phrases
+	return nil
+}
+
+func TestRoom_UpdateVocabulary(t *testing.T) {
+	service := &vocabularyService{updates: make(chan []string, 1)}
+	room := NewRoom("vocab", service, transcription.Options{Vocabulary: []string{"Tolka"}})
+	go room.Run(func() {})
+	defer room.Close()
+
+	room.UpdateVocabulary([]string{" Zürich ", "zürich", "", "Fritz"})
+
+	select {
+	case phrases := <-service.updates:
+		if len(phrases) != 2 || phrases[0] != "Zürich" || phrases[1] != "Fritz" {
+			t.Errorf("Expected normalized phrases [Zürich Fritz], got %v", phrases)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the vocabulary update")
+	}
+	if vocabulary := room.Info().Options.Vocabulary; len(vocabulary) != 2 {
+		t.Errorf("Expected room options to hold the new vocabulary, got %v", vocabulary)
+	}
+}
+
+func TestRoom_Failover(t *testing.T) {
+	primary := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+	backup := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "primary", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return primary, nil
+	})
+	hub.RegisterProvider(ProviderInfo{Name: "backup", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return backup, nil
+	})
+
+	if _, err := hub.CreateSession([]string{"primary", "missing"}, transcription.Options{}); err == nil {
+		t.Error("Expected error for unknown provider in chain")
+	}
+
+	roomID, err := hub.CreateSession([]string{"primary", "backup"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(roomID)
+	conn := joinRoom(t, hub, roomID, "")
+
+	primary.resultChan <- transcription.TranscriptResult{Text: "Hallo", Start: 0.5, End: 4}
+	readType(t, conn, "transcript")
+
+	primary.errorChan <- errors.New("quota exceeded")
+	msg := readType(t, conn, "provider_switch")
+	payload := msg.Payload.(map[string]interface{})
+	if payload["from"] != "primary" || payload["to"] != "backup" {
+		t.Errorf("Unexpected switch payload: %v", payload)
+	}
+
+	// Der Backup-Provider zählt wieder ab null, das Transkript läuft weiter
+	backup.resultChan <- transcription.TranscriptResult{Text: "Weiter geht's", Start: 1, End: 2}
+	msg = readType(t, conn, "transcript")
+	payload = msg.Payload.(map[string]interface{})
+	if payload["text"] != "Weiter geht's" {
+		t.Errorf("Expected transcript from backup provider, got %v", msg.Payload)
+	}
+	if payload["start"] != 5.0 || payload["end"] != 6.0 {
+		t.Errorf("Expected timings after the primary's, got %v-%v", payload["start"], payload["end"])
+	}
+
+	// Ohne weiteren Provider erfahren die Clients, dass die Untertitel enden
+	backup.errorChan <- errors.New("service down")
+	msg = readType(t, conn, "provider_switch")
+	payload = msg.Payload.(map[string]interface{})
+	if payload["from"] != "backup" || payload["to"] != "" {
+		t.Errorf("Expected final switch without target, got %v", payload)
+	}
+	deadline := time.Now().Add(time.Second)
+	for hub.getRoom(roomID) != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hub.getRoom(roomID) != nil {
+		t.Error("Expected the room to close")
+	}
+}
+
+// statusService reports reconnect states like the reconnect decorator
+type statusService struct {
+	MockService
+	status chan transcription.Status
+}
+
+func (s *statusService) StatusChan() <-chan transcription.Status { return s.status }
+
+func TestCreateSession_MixedAudioFormats(t *testing.T) {
+	hub := NewHub()
+	for name, format := range map[string]transcription.AudioFormat{
+		"azure":    transcription.AudioPCM16,
+		"deepgram": transcription.AudioWebM,
+		"mock":     "", // Akzeptiert jedes Audio
+	} {
+		hub.RegisterProvider(ProviderInfo{
+			Name:         name,
+			Configured:   true,
+			Capabilities: transcription.Capabilities{AudioFormat: format},
+		}, func(opts transcription.Options) (transcription.Service, error) {
+			return &MockService{resultChan: make(chan transcription.TranscriptResult), errorChan: make(chan error)}, nil
+		})
+	}
+
+	// Der Host wählt seine Audio-Pipeline nach dem ersten Provider, Deepgram bekäme PCM
+	if _, err := hub.CreateSession([]string{"azure", "deepgram", "mock"}, transcription.Options{}); err == nil {
+		t.Error("Expected error for a chain with PCM and WebM providers")
+	}
+
+	roomID, err := hub.CreateSession([]string{"mock", "deepgram", "mock"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Expected providers without format to join any chain, got %v", err)
+	}
+	defer hub.CloseSession(roomID)
+	if format := hub.getRoom(roomID).format; format != transcription.AudioWebM {
+		t.Errorf("Expected the chain format webm, got %q", format)
+	}
+}
+
+func TestRoom_ProviderStatus(t *testing.T) {
+	service := &statusService{
+		MockService: MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		},
+		status: make(chan transcription.Status),
+	}
+	_, _, conn := newTestRoom(t, service)
+
+	service.status <- transcription.Status{State: transcription.StateReconnecting, Attempt: 2}
+
+	payload := readType(t, conn, "provider_status").Payload.(map[string]interface{})
+	if payload["state"] != "reconnecting" || payload["provider"] != "test" {
+		t.Errorf("Unexpected status message: %v", payload)
+	}
+}
+
+// activityService reports speech activity like Deepgram's SpeechStarted
+type activityService struct {
+	MockService
+	activity chan transcription.SpeechEvent
+}
+
+func (s *activityService) ActivityChan() <-chan transcription.SpeechEvent { return s.activity }
+
+func TestRoom_SpeechActivity(t *testing.T) {
+	service := &activityService{
+		MockService: MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		},
+		activity: make(chan transcription.SpeechEvent),
+	}
+	_, _, conn := newTestRoom(t, service)
+
+	service.activity <- transcription.SpeechEvent{Type: transcription.SpeechStart}
+	service.activity <- transcription.SpeechEvent{Type: transcription.SpeechEnd, Speaker: "Speaker 1", Time: 4.5}
+
+	expected := []struct{ typ, speaker string }{{"speech_start", ""}, {"speech_end", "Speaker 1"}}
+	for _, want := range expected {
+		payload := readType(t, conn, want.typ).Payload.(map[string]interface{})
+		speaker, _ := payload["speaker"].(string)
+		if speaker != want.speaker || payload["provider"] != "test" {
+			t.Errorf("Expected %s by %q, got %v", want.typ, want.speaker, payload)
+		}
+	}
+}
+
+func TestRoom_MockFaults(t *testing.T) {
+	backup := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "mock", Configured: true, Options: mock.Options()}, func(opts transcription.Options) (transcription.Service, error) {
+		return mock.FromParams("", opts.ProviderOptions["mock"])
+	})
+	hub.RegisterProvider(ProviderInfo{Name: "backup", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return backup, nil
+	})
+
+	// Der Mock schliesst seinen Result-Channel nach einer halben Sekunde Audio
+	roomID, err := hub.CreateSession([]string{"mock", "backup"}, transcription.Options{
+		ProviderOptions: map[string]transcription.ProviderParams{"mock": {"close_after": "0.5"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(roomID)
+	conn := joinRoom(t, hub, roomID, "host")
+
+	// Audio wird ohne Puffer weitergereicht, daher fortlaufend senden
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				conn.WriteMessage(websocket.BinaryMessage, []byte("fake-audio-data"))
+			}
+		}
+	}()
+
+	var msg WSMessage
+	var transcripts int
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Expected provider_switch, got error: %v", err)
+		}
+		if msg.Type == "transcript" {
+			transcripts++
+		}
+		if msg.Type != "provider_switch" {
+			continue
+		}
+		payload := msg.Payload.(map[string]interface{})
+		if payload["from"] != "mock" || payload["to"] != "backup" || payload["reason"] != "result channel closed" {
+			t.Errorf("Unexpected switch payload: %v", payload)
+		}
+		break
+	}
+	if transcripts == 0 {
+		t.Error("Expected transcripts before the failure")
+	}
+}
+
+func TestCreateSession_Record(t *testing.T) {
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return &MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		}, nil
+	})
+
+	if _, err := hub.CreateSession([]string{"test"}, transcription.Options{Record: true, AudioConsent: true}); err == nil {
+		t.Error("Expected error while recording is disabled")
+	}
+
+	dir := t.TempDir()
+	hub.EnableRecording(dir)
+	if _, err := hub.CreateSession([]string{"test"}, transcription.Options{Record: true}); err == nil {
+		t.Error("Expected error for recording without audio consent")
+	}
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{Record: true, AudioConsent: true})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(roomID)
+
+	if _, err := os.Stat(filepath.Join(dir, roomID+"-test.jsonl")); err != nil {
+		t.Errorf("Expected recording file: %v", err)
+	}
+}
+
+func TestRoom_AudioRecording(t *testing.T) {
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{
+		Name:         "pcm",
+		Configured:   true,
+		Capabilities: transcription.Capabilities{AudioFormat: transcription.AudioPCM16},
+	}, func(opts transcription.Options) (transcription.Service, error) {
+		return &MockService{
+			resultChan: make(chan transcription.TranscriptResult),
+			errorChan:  make(chan error),
+		}, nil
+	})
+	dir := t.TempDir()
+	hub.EnableRecording(dir)
+
+	// Ohne Einwilligung wird nichts gespeichert
+	plainID, err := hub.CreateSession([]string{"pcm"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(plainID)
+
+	roomID, err := hub.CreateSession([]string{"pcm"}, transcription.Options{AudioConsent: true})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	for _, id := range []string{plainID, roomID} {
+		conn := joinRoom(t, hub, id, "host")
+		for i := 0; i < 3; i++ {
+			conn.WriteMessage(websocket.BinaryMessage, make([]byte, 320))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if _, err := hub.AudioFile(roomID, 1); !errors.Is(err, ErrRecordingActive) {
+		t.Errorf("Expected ErrRecordingActive while the room runs, got %v", err)
+	}
+
+	hub.CloseSession(roomID)
+	var path string
+	deadline := time.Now().Add(time.Second)
+	for path == "" && time.Now().Before(deadline) {
+		path, _ = hub.AudioFile(roomID, 1)
+		time.Sleep(10 * time.Millisecond)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected stored audio: %v", err)
+	}
+	if filepath.Ext(path) != ".wav" || info.Size() != 44+3*320 {
+		t.Errorf("Expected WAV with 3 chunks, got %s with %d bytes", path, info.Size())
+	}
+
+	if _, err := hub.AudioFile(plainID, 1); err == nil {
+		t.Error("Expected no audio for session without consent")
+	}
+	if _, err := hub.AudioFile("../"+roomID, 1); err == nil {
+		t.Error("Expected error for invalid room ID")
+	}
+}
+
+func TestAudioRecording_ActualFormat(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "room1")
+	recording := newAudioRecording(base)
+
+	// Der Provider erwartet PCM, der Host sendet WebM und verbindet sich neu
+	header := []byte{0x1a, 0x45, 0xdf, 0xa3}
+	recording.Write(transcription.AudioPCM16, append(header, "eins"...))
+	recording.Write(transcription.AudioPCM16, []byte("cluster"))
+	recording.Write(transcription.AudioPCM16, append(header, "zwei"...))
+	recording.Close()
+
+	for part, want := range map[int]string{1: "\x1a\x45\xdf\xa3einscluster", 2: "\x1a\x45\xdf\xa3zwei"} {
+		data, err := os.ReadFile(partBase(base, part) + ".webm")
+		if err != nil || string(data) != want {
+			t.Errorf("Part %d: expected %q, got %q (%v)", part, want, data, err)
+		}
+	}
+}
+
+func TestTranscriptHistory(t *testing.T) {
+	now := time.Now()
+	history := transcriptHistory{limits: HistoryLimits{MaxSegments: 2, MaxAge: time.Minute}}
+
+	history.Add(transcription.TranscriptResult{Text: "eins"}, now.Add(-2*time.Minute))
+	history.Add(transcription.TranscriptResult{Text: "zwei"}, now.Add(-30*time.Second))
+	history.Add(transcription.TranscriptResult{Text: "Partial", IsPartial: true}, now)
+	if got := history.Segments(now); len(got) != 1 || got[0].Text != "zwei" {
+		t.Errorf("Expected only 'zwei' within a minute, got %+v", got)
+	}
+
+	history.Add(transcription.TranscriptResult{Text: "drei"}, now)
+	history.Add(transcription.TranscriptResult{Text: "vier"}, now)
+	got := history.Segments(now)
+	if len(got) != 2 || got[0].Text != "drei" || got[1].Text != "vier" {
+		t.Errorf("Expected the last two segments in order, got %+v", got)
+	}
+}
+
+func TestRoom_History(t *testing.T) {
+	service := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return service, nil
+	})
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(roomID)
+
+	service.resultChan <- transcription.TranscriptResult{Text: "Guten Morgen", Speaker: "Speaker 1"}
+	service.resultChan <- transcription.TranscriptResult{Text: "Wie", IsPartial: true}
+	service.resultChan <- transcription.TranscriptResult{Text: "Wie geht's?", Speaker: "Speaker 2"}
+
+	// Ein Viewer, der zu spät kommt, erhält das bisherige Gespräch
+	conn := dialRoom(t, hub, roomID, "")
+	readType(t, conn, "speaker_update")
+	var history HistoryPayload
+	data, _ := json.Marshal(readType(t, conn, "history").Payload)
+	if err := json.Unmarshal(data, &history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+
+	segments := history.Segments
+	if len(segments) != 2 || segments[0].Text != "Guten Morgen" || segments[1].Text != "Wie geht's?" {
+		t.Fatalf("Unexpected history: %+v", segments)
+	}
+	if segments[1].UtteranceID == "" || segments[1].ReceivedAt.IsZero() {
+		t.Errorf("Expected utterance ID and receive time, got %+v", segments[1])
+	}
+}
+
+func TestRoom_Store(t *testing.T) {
+	transcripts, err := store.OpenJSONL(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open store failed: %v", err)
+	}
+	service := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+	hub := NewHub()
+	hub.SetStore(transcripts)
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return service, nil
+	})
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{Language: "en-US"})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	service.resultChan <- transcription.TranscriptResult{Text: "Good", IsPartial: true}
+	service.resultChan <- transcription.TranscriptResult{Text: "Good morning", Speaker: "Speaker 1"}
+	hub.getRoom(roomID).UpdateSpeaker("Speaker 1", "Anna", 90)
+	hub.CloseSession(roomID)
+
+	// Die Session bleibt nach dem Schliessen des Raums erhalten
+	var session store.Session
+	deadline := time.Now().Add(time.Second)
+	for session.Closed.IsZero() && time.Now().Before(deadline) {
+		session, _ = transcripts.Session(roomID)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if session.Provider != "test" || session.Options.Language != "en-US" || session.Closed.IsZero() {
+		t.Errorf("Unexpected stored session: %+v", session)
+	}
+
+	segments, _ := transcripts.Segments(roomID)
+	if len(segments) != 1 || segments[0].Text != "Good morning" || segments[0].UtteranceID == "" {
+		t.Errorf("Expected only the final segment, got %+v", segments)
+	}
+	speakers, _ := transcripts.Speakers(roomID)
+	if speakers["Speaker 1"].Name != "Anna" {
+		t.Errorf("Expected stored speaker name, got %v", speakers)
+	}
+	// Der Export liest den Store, auch wenn der Raum weg ist
+	transcript, err := hub.Transcript(roomID)
+	if err != nil || len(transcript.Segments) != 1 || transcript.SpeakerName("Speaker 1") != "Anna" {
+		t.Errorf("Expected stored transcript, got %+v (%v)", transcript, err)
+	}
+}
+
+func TestHub_Transcript(t *testing.T) {
+	service := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+	hub := NewHub()
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return service, nil
+	})
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{Language: "en-US"})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Ohne Store kommt das Transkript aus der History des laufenden Raums
+	service.resultChan <- transcription.TranscriptResult{Text: "Hello", Speaker: "Speaker 1"}
+	hub.getRoom(roomID).UpdateSpeaker("Speaker 1", "Anna", 90)
+	service.resultChan <- transcription.TranscriptResult{Text: "Hi", IsPartial: true}
+
+	transcript, err := hub.Transcript(roomID)
+	if err != nil {
+		t.Fatalf("Transcript failed: %v", err)
+	}
+	if transcript.Session.ID != roomID || transcript.Session.Provider != "test" || transcript.Session.Created.IsZero() {
+		t.Errorf("Unexpected session: %+v", transcript.Session)
+	}
+	if len(transcript.Segments) != 1 || transcript.SpeakerName("Speaker 1") != "Anna" {
+		t.Errorf("Expected one final segment by Anna, got %+v", transcript)
+	}
+
+	if _, err := hub.Transcript("missing"); err == nil {
+		t.Error("Expected error for unknown session")
+	}
+	hub.CloseSession(roomID)
+}
+
+func TestRoom_EditSegment(t *testing.T) {
+	transcripts, err := store.OpenJSONL(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open store failed: %v", err)
+	}
+	service := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+	hub := NewHub()
+	hub.SetStore(transcripts)
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return service, nil
+	})
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(roomID)
+
+	service.resultChan <- transcription.TranscriptResult{Text: "Guten Morgen Ana", Speaker: "Speaker 1"}
+	service.resultChan <- transcription.TranscriptResult{Text: "Hallo", Speaker: "Speaker 1"}
+	service.resultChan <- transcription.TranscriptResult{Text: "Äh", Speaker: "Speaker 2"}
+
+	viewer := joinRoom(t, hub, roomID, "")
+
+	// Viewer dürfen nichts korrigieren. Die Antwort auf get_vocabulary zeigt,
+	// dass der Befehl davor verarbeitet wurde.
+	viewer.WriteJSON(ClientCommand{Type: "delete_segment", UtteranceID: "u1"})
+	viewer.WriteJSON(ClientCommand{Type: "get_vocabulary"})
+	readType(t, viewer, "vocabulary_update")
+
+	host := joinRoom(t, hub, roomID, "host")
+	viewer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	host.WriteJSON(ClientCommand{Type: "edit_segment", UtteranceID: "u1", Text: " Guten Morgen Anna "})
+	host.WriteJSON(ClientCommand{Type: "edit_segment", UtteranceID: "u1", Text: ""}) // Abgelehnt
+	host.WriteJSON(ClientCommand{Type: "reassign_segment", UtteranceID: "u2", SpeakerID: "Speaker 2"})
+	host.WriteJSON(ClientCommand{Type: "delete_segment", UtteranceID: "u3"})
+	host.WriteJSON(ClientCommand{Type: "delete_segment", UtteranceID: "u9"}) // Unbekannt
+
+	var edits []SegmentEdit
+	for len(edits) < 3 {
+		var raw struct {
+			Type    string      `json:"type"`
+			Payload SegmentEdit `json:"payload"`
+		}
+		if err := viewer.ReadJSON(&raw); err != nil {
+			t.Fatalf("Expected segment_edit, got %v", err)
+		}
+		if raw.Type == "segment_edit" {
+			edits = append(edits, raw.Payload)
+		}
+	}
+	if edits[0].Action != store.EditText || edits[0].Text != "Guten Morgen Anna" || edits[0].Revision != 2 {
+		t.Errorf("Unexpected text edit: %+v", edits[0])
+	}
+	if edits[1].Action != store.EditSpeaker || edits[1].Speaker != "Speaker 2" || edits[2].Action != store.EditDelete {
+		t.Errorf("Unexpected edits: %+v", edits[1:])
+	}
+
+	// History und Store zeigen den korrigierten Stand
+	history := hub.getRoom(roomID).history.Segments(time.Now())
+	if len(history) != 2 || history[0].Text != "Guten Morgen Anna" || history[1].Speaker != "Speaker 2" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+	segments, _ := transcripts.Segments(roomID)
+	if len(segments) != 2 || !reflect.DeepEqual(segments[0].TranscriptResult, history[0].TranscriptResult) || !reflect.DeepEqual(segments[1].TranscriptResult, history[1].TranscriptResult) {
+		t.Errorf("Expected stored segments to match the history, got %+v", segments)
+	}
+}
+
+func TestRoom_EditSegmentFromStore(t *testing.T) {
+	transcripts, err := store.OpenJSONL(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open store failed: %v", err)
+	}
+	service := &MockService{
+		resultChan: make(chan transcription.TranscriptResult),
+		errorChan:  make(chan error),
+	}
+	hub := NewHub()
+	hub.SetStore(transcripts)
+	hub.SetHistoryLimits(HistoryLimits{MaxSegments: 1})
+	hub.RegisterProvider(ProviderInfo{Name: "test", Configured: true}, func(opts transcription.Options) (transcription.Service, error) {
+		return service, nil
+	})
+	roomID, err := hub.CreateSession([]string{"test"}, transcription.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer hub.CloseSession(roomID)
+
+	// u1 fällt aus der History, bleibt aber im Store korrigierbar
+	service.resultChan <- transcription.TranscriptResult{Text: "Guten Morgen Ana", Speaker: "Speaker 1"}
+	service.resultChan <- transcription.TranscriptResult{Text: "Hallo", Speaker: "Speaker 1"}
+	room := hub.getRoom(roomID)
+	room.EditSegment(SegmentEdit{UtteranceID: "u1", Action: store.EditText, Text: "Guten Morgen Anna"})
+
+	var segments []store.Segment
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		segments, _ = transcripts.Segments(roomID)
+		if len(segments) == 2 && segments[0].Text == "Guten Morgen Anna" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(segments) != 2 || segments[0].Text != "Guten Morgen Anna" || segments[0].Revision != 2 {
+		t.Errorf("Expected the stored segment to be edited, got %+v", segments)
+	}
+}